@@ -0,0 +1,140 @@
+package codeup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// archiveEntry is a regular file unpacked from an archive.
+type archiveEntry struct {
+	name    string
+	content string
+}
+
+// readArchive returns the migration files in the archive at Config.Path,
+// which is read once; their contents are served from memory.
+// The archive and each of its files must fit in Option.MaxSize.
+func (s *CodeUp) readArchive(ctx context.Context) ([]*file, error) {
+	name := s.option.Config.Path
+	ref := s.option.Config.listRef()
+	content, _, err := s.fetchAt(ctx, s.blobPath(path.Base(name)), s.option.Config.readRef(ref))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := unpack(name, content, s.option.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("unpack %s: %w", name, err)
+	}
+
+	var files []*file
+	for _, e := range entries {
+		e := e
+		f, err := s.entryFile(e.name, &devops.ListRepositoryTreeResponseBodyResult{
+			Name: tea.String(e.name),
+			Type: tea.String("blob"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		f.ref = ref
+		f.content = &e.content
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// unpack returns the regular files of the archive name, which is
+// a zip or tar file, optionally gzip compressed, by its extension.
+// A file larger than max, if positive, fails with ErrTooLarge.
+func unpack(name, content string, max int64) ([]archiveEntry, error) {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return unzip(content, max)
+	case strings.HasSuffix(name, ".tar"):
+		return untar(strings.NewReader(content), max)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		zr, err := gzip.NewReader(strings.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return untar(zr, max)
+	}
+	return nil, fmt.Errorf("unknown archive format")
+}
+
+// readEntry reads the archive file name from r,
+// failing with ErrTooLarge once it is larger than max, if positive.
+func readEntry(r io.Reader, name string, max int64) (string, error) {
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return "", err
+	}
+	if max > 0 && int64(buf.Len()) > max {
+		return "", fmt.Errorf("%w: %s is larger than the limit of %d bytes", ErrTooLarge, name, max)
+	}
+	return buf.String(), nil
+}
+
+func unzip(content string, max int64) ([]archiveEntry, error) {
+	zr, err := zip.NewReader(strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []archiveEntry
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := readEntry(r, f.Name, max)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archiveEntry{name: cleanPath(f.Name), content: content})
+	}
+	return entries, nil
+}
+
+func untar(r io.Reader, max int64) ([]archiveEntry, error) {
+	tr := tar.NewReader(r)
+	var entries []archiveEntry
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := readEntry(tr, h.Name, max)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archiveEntry{name: cleanPath(h.Name), content: content})
+	}
+}
@@ -0,0 +1,152 @@
+package codeup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var archiveFiles = []archiveEntry{
+	{"1_init.up.sql", "CREATE TABLE a;"},
+	{"1_init.down.sql", "DROP TABLE a;"},
+	{"sub/2_users.up.sql", "CREATE TABLE users;"},
+}
+
+func zipped(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("dir/")
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func tarred(t *testing.T, entries []archiveEntry, compress bool) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	}
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(e.content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compress {
+		zw.Close()
+	}
+	return buf.String()
+}
+
+func TestUnpack(t *testing.T) {
+	tests := map[string]string{
+		"m.zip":    zipped(t, archiveFiles),
+		"m.tar":    tarred(t, archiveFiles, false),
+		"m.tar.gz": tarred(t, archiveFiles, true),
+		"m.tgz":    tarred(t, archiveFiles, true),
+	}
+	for name, content := range tests {
+		got, err := unpack(name, content, 0)
+		if err != nil {
+			t.Errorf("unpack(%s): %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, archiveFiles) {
+			t.Errorf("unpack(%s) = %v, want %v", name, got, archiveFiles)
+		}
+	}
+	for _, name := range []string{"m.rar", "m.zip"} {
+		if _, err := unpack(name, "junk", 0); err == nil {
+			t.Errorf("unpack(%s) of junk succeeded", name)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	client := newFakeClient(map[string]string{"db/m.zip": zipped(t, archiveFiles)})
+	option := testOption("db/m.zip")
+	option.Config.Archive = true
+	d := newTestDriver(t, client, option)
+
+	if got := d.Versions(); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Errorf("Versions() = %v, want [1 2]", got)
+	}
+	if got := readUp(t, d, 2); got != "CREATE TABLE users;" {
+		t.Errorf("ReadUp(2) = %q", got)
+	}
+	if got := readDown(t, d, 1); got != "DROP TABLE a;" {
+		t.Errorf("ReadDown(1) = %q", got)
+	}
+	if n, m := client.count("GetFileBlobs"), client.count("ListRepositoryTree"); n != 1 || m != 0 {
+		t.Errorf("%d reads and %d listings, want the archive read once", n, m)
+	}
+}
+
+// TestArchiveMaxSize checks that Option.MaxSize limits the archive
+// and each file unpacked from it.
+func TestArchiveMaxSize(t *testing.T) {
+	bomb := []archiveEntry{{"1_a.up.sql", strings.Repeat("0", 1<<20)}}
+	tests := map[string]string{
+		"m.zip":    zipped(t, bomb),
+		"m.tar.gz": tarred(t, bomb, true),
+	}
+	for name, content := range tests {
+		if len(content) > 1<<16 {
+			t.Fatalf("%s is %d bytes, want a small archive", name, len(content))
+		}
+		if _, err := unpack(name, content, 1<<16); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("unpack(%s) = %v, want ErrTooLarge", name, err)
+		}
+
+		client := newFakeClient(map[string]string{"db/" + name: content})
+		option := testOption("db/" + name)
+		option.Config.Archive = true
+		option.MaxSize = 1 << 16
+		if _, err := WithInstance(client, option); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("WithInstance(%s) = %v, want ErrTooLarge", name, err)
+		}
+		option.MaxSize = int64(len(content)) - 1
+		if _, err := WithInstance(client, option); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("WithInstance(%s) with a smaller MaxSize = %v, want ErrTooLarge", name, err)
+		}
+	}
+}
+
+// TestArchivePath checks that the archive is read like other files,
+// with Config.BlobBasePath, Option.PathRewrite and Config.FallbackRef.
+func TestArchivePath(t *testing.T) {
+	client := newFakeClient(nil)
+	client.set("stable", "mirror/m.zip", zipped(t, archiveFiles))
+	option := testOption("db/m.zip")
+	option.Config.Archive = true
+	option.Config.BlobBasePath = "sql"
+	option.Config.FallbackRef = "stable"
+	option.PathRewrite = func(p string) string { return "mirror/" + strings.TrimPrefix(p, "sql/") }
+	d := newTestDriver(t, client, option)
+
+	if got := readUp(t, d, 2); got != "CREATE TABLE users;" {
+		t.Errorf("ReadUp(2) = %q", got)
+	}
+}
@@ -0,0 +1,35 @@
+package codeup
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ReadAsset returns the content of the file at relPath, like "data/users.csv",
+// which a migration of version refers to. relPath is relative to the
+// migration directory, Config.BlobBasePath or Config.Path, and is read
+// from the ref of the migration. Paths leaving that directory are rejected.
+func (s *CodeUp) ReadAsset(version uint, relPath string) (io.ReadCloser, error) {
+	op := "read asset of version " + strconv.FormatUint(uint64(version), 10)
+	clean := path.Clean(relPath)
+	if relPath == "" || path.IsAbs(relPath) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, &fs.PathError{Op: op, Path: relPath, Err: fs.ErrInvalid}
+	}
+
+	if _, err := s.loaded(); err != nil {
+		return nil, err
+	}
+	ref, ok := s.RefOf(version)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: s.option.Config.Path, Err: fs.ErrNotExist}
+	}
+
+	content, _, err := s.fetchAt(s.withVersion(s.ctx, version), s.blobPath(clean), ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.newBodyReader(strings.NewReader(content)), nil
+}
@@ -0,0 +1,33 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestReadAsset(t *testing.T) {
+	files := map[string]string{
+		"db/1_a.up.sql":       "",
+		"db/data/users.csv":   "id\n1\n",
+		"secret/password.txt": "hunter2",
+	}
+	d := newTestDriver(t, newFakeClient(files), testOption("db"))
+
+	r, err := d.ReadAsset(1, "data/users.csv")
+	if got := readBody(t, r, err); got != "id\n1\n" {
+		t.Errorf("ReadAsset = %q", got)
+	}
+
+	for _, p := range []string{"", ".", "..", "../secret/password.txt", "data/../../secret/password.txt", "/secret/password.txt"} {
+		if _, err := d.ReadAsset(1, p); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("ReadAsset(%q): err = %v, want fs.ErrInvalid", p, err)
+		}
+	}
+	if _, err := d.ReadAsset(2, "data/users.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadAsset of a missing version: err = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := d.ReadAsset(1, "data/none.csv"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("ReadAsset of a missing file: err = %v, want ErrFileNotFound", err)
+	}
+}
@@ -0,0 +1,105 @@
+package codeup
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// walkPaths returns the migration files of each of dirs at ref,
+// in the order of dirs.
+//
+// Several dirs are listed by one recursive listing of their common parent
+// and partitioned in memory. Dirs whose only common parent is the
+// repository root, which is too large to list recursively,
+// and version directories are walked one by one.
+func (s *CodeUp) walkPaths(ctx context.Context, ref string, dirs []string) ([][]*file, error) {
+	listed := make([][]*file, len(dirs))
+	base := s.option.Config.listPath()
+	parent := ""
+	if len(dirs) > 1 {
+		parent = cleanPath(path.Join(base, commonParent(dirs)))
+	}
+	if parent == "" || s.option.Config.VersionDirs {
+		for i, dir := range dirs {
+			found, err := s.walk(ctx, ref, dir)
+			if err != nil {
+				return nil, err
+			}
+			listed[i] = found
+		}
+		return listed, nil
+	}
+
+	request := s.treeRequest(ref, parent)
+	request.Type = tea.String("RECURSIVE")
+	entries, err := s.listTree(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, dir := range dirs {
+		dir = cleanPath(dir)
+		for _, v := range entries {
+			if tea.StringValue(v.Type) == "tree" {
+				continue
+			}
+			name, ok := relativePath(base, tea.StringValue(v.Path))
+			if !ok {
+				s.log(Event{Op: OpSkip, Path: tea.StringValue(v.Path), Reason: "outside the listing path"})
+				continue
+			}
+			if !s.inDir(dir, name) {
+				continue
+			}
+			f, err := s.entryFile(name, v)
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				listed[i] = append(listed[i], f)
+			}
+		}
+	}
+	return listed, nil
+}
+
+// inDir reports whether the file name is directly in dir,
+// or anywhere below it if Config.Recursive is set.
+func (s *CodeUp) inDir(dir, name string) bool {
+	d := path.Dir(name)
+	if d == "." {
+		d = ""
+	}
+	if d == dir {
+		return true
+	}
+	return s.option.Config.Recursive && (dir == "" || strings.HasPrefix(d, dir+"/"))
+}
+
+// commonParent returns the deepest directory containing all of dirs,
+// which are relative to the listing path.
+func commonParent(dirs []string) string {
+	parent := strings.Split(cleanPath(dirs[0]), "/")
+	for _, dir := range dirs[1:] {
+		parts := strings.Split(cleanPath(dir), "/")
+		n := 0
+		for n < len(parent) && n < len(parts) && parent[n] == parts[n] {
+			n++
+		}
+		parent = parent[:n]
+	}
+	return strings.Join(parent, "/")
+}
+
+// relativePath returns p, a path from the repository root, relative to base.
+func relativePath(base, p string) (string, bool) {
+	base, p = cleanPath(base), cleanPath(p)
+	if base == "" {
+		return p, p != ""
+	}
+	rel := strings.TrimPrefix(p, base+"/")
+	return rel, rel != p
+}
@@ -0,0 +1,85 @@
+package codeup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommonParent(t *testing.T) {
+	tests := []struct {
+		dirs []string
+		want string
+	}{
+		{[]string{"a/b"}, "a/b"},
+		{[]string{"a/b", "a/c"}, "a"},
+		{[]string{"a/b/c", "/a/b/d/"}, "a/b"},
+		{[]string{"a", "b"}, ""},
+		{[]string{"ab", "a"}, ""},
+		{[]string{"a", "a/b"}, "a"},
+	}
+	for _, tt := range tests {
+		if got := commonParent(tt.dirs); got != tt.want {
+			t.Errorf("commonParent(%q) = %q, want %q", tt.dirs, got, tt.want)
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		base, p string
+		want    string
+		ok      bool
+	}{
+		{"", "db/1_a.up.sql", "db/1_a.up.sql", true},
+		{"", "", "", false},
+		{"db", "db/1_a.up.sql", "1_a.up.sql", true},
+		{"/db/", "db/sub/1_a.up.sql", "sub/1_a.up.sql", true},
+		{"db", "dbx/1_a.up.sql", "dbx/1_a.up.sql", false},
+		{"db", "db", "db", false},
+	}
+	for _, tt := range tests {
+		got, ok := relativePath(tt.base, tt.p)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relativePath(%q, %q) = %q, %v; want %q, %v", tt.base, tt.p, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestInDir(t *testing.T) {
+	tests := []struct {
+		dir, name string
+		recursive bool
+		want      bool
+	}{
+		{"", "1_a.up.sql", false, true},
+		{"", "a/1_a.up.sql", false, false},
+		{"", "a/1_a.up.sql", true, true},
+		{"a", "a/1_a.up.sql", false, true},
+		{"a", "a/b/1_a.up.sql", false, false},
+		{"a", "a/b/1_a.up.sql", true, true},
+		{"a", "ab/1_a.up.sql", true, false},
+	}
+	for _, tt := range tests {
+		s := &CodeUp{option: Option{Config: Config{Recursive: tt.recursive}}}
+		if got := s.inDir(tt.dir, tt.name); got != tt.want {
+			t.Errorf("inDir(%q, %q) recursive %v = %v, want %v", tt.dir, tt.name, tt.recursive, got, tt.want)
+		}
+	}
+}
+
+// TestPathsUnderRoot checks that paths with no common parent but the
+// repository root are listed one by one.
+func TestPathsUnderRoot(t *testing.T) {
+	client := newFakeClient(map[string]string{"a/1_a.up.sql": "", "b/2_b.up.sql": ""})
+	option := testOption("")
+	option.Config.Paths = []string{"a", "b"}
+	d := newTestDriver(t, client, option)
+
+	if got := d.Versions(); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Errorf("Versions() = %v, want [1 2]", got)
+	}
+	calls := client.received("ListRepositoryTree")
+	if len(calls) != 2 || calls[0].path != "a" || calls[1].path != "b" || calls[0].typ == "RECURSIVE" {
+		t.Errorf("listings = %+v, want a and b listed one by one", calls)
+	}
+}
@@ -0,0 +1,178 @@
+package codeup
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a file content cache that may be shared between drivers.
+// It must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (content string, ok bool)
+	Set(key, content string)
+}
+
+// NewCache returns an in-memory Cache whose entries never expire.
+func NewCache() Cache {
+	return NewCacheTTL(0, nil)
+}
+
+// NewCacheTTL returns an in-memory Cache whose entries expire ttl after
+// they are set, or never if ttl is zero. Time is told by clock,
+// or by the time package if it is nil.
+func NewCacheTTL(ttl time.Duration, clock Clock) Cache {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &memoryCache{entries: make(map[string]memoryEntry), ttl: ttl, clock: clock}
+}
+
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+	ttl     time.Duration
+	clock   Clock
+}
+
+type memoryEntry struct {
+	content string
+	expires time.Time // zero if the entry never expires.
+}
+
+func (c *memoryCache) Get(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	if !ok || !e.expires.IsZero() && c.clock.Now().After(e.expires) {
+		return "", false
+	}
+	return e.content, true
+}
+
+func (c *memoryCache) Set(key, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := memoryEntry{content: content}
+	if c.ttl > 0 {
+		e.expires = c.clock.Now().Add(c.ttl)
+	}
+	c.entries[key] = e
+}
+
+// fetchShared is fetchDisk with Option.Cache in front of it.
+func (s *CodeUp) fetchShared(ctx context.Context, raw string) (string, string, error) {
+	c := s.option.Cache
+	if c == nil {
+		return s.fetchDisk(ctx, raw)
+	}
+
+	key := s.sharedCacheKey(raw)
+	if content, ok := c.Get(key); ok {
+		if s.option.Metrics != nil {
+			s.option.Metrics.IncCacheHit()
+		}
+		return content, s.fileRef(raw), nil
+	}
+
+	content, ref, err := s.fetchDisk(ctx, raw)
+	if err != nil {
+		return "", "", err
+	}
+	if s.blobId(raw) == "" || s.readAtListed(raw, ref) {
+		c.Set(key, content)
+	}
+	return content, ref, nil
+}
+
+// sharedCacheKey returns the key of the file with the raw name in Option.Cache,
+// which is cacheKey qualified by the organization and project.
+func (s *CodeUp) sharedCacheKey(raw string) string {
+	return s.option.Config.OrganizationId + "/" + s.option.Config.ProjectId + "/" + s.cacheKey(raw)
+}
+
+// contentCache caches file contents by key.
+type contentCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	ttl     time.Duration // how long entries are kept, forever if zero.
+	clock   Clock
+}
+
+type cacheEntry struct {
+	done    chan struct{}
+	content string
+	err     error
+	expires time.Time // set once done if the cache has a ttl.
+}
+
+func newContentCache(ttl time.Duration, clock Clock) *contentCache {
+	return &contentCache{entries: make(map[string]*cacheEntry), ttl: ttl, clock: clock}
+}
+
+// expired reports whether e is done and past its ttl.
+func (e *cacheEntry) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return !e.expires.IsZero() && now.After(e.expires)
+	default:
+		return false
+	}
+}
+
+// get returns the content cached for key and whether it was cached.
+// On a miss, fetch is called once and concurrent callers share its result.
+// Failed fetches are not cached, and entries are fetched again after the ttl,
+// except blob id entries: a blob id names one content, while fetching again
+// reads whatever the path holds now, which may be a different blob.
+func (c *contentCache) get(key string, fetch func() (string, error)) (content string, hit bool, err error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && !e.expired(c.clock.Now()) {
+		c.mu.Unlock()
+		<-e.done
+		return e.content, true, e.err
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.content, e.err = fetch()
+	if c.ttl > 0 && !strings.HasPrefix(key, blobKeyPrefix) {
+		e.expires = c.clock.Now().Add(c.ttl)
+	}
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.content, false, e.err
+}
+
+// clear removes all entries.
+func (c *contentCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cacheEntry)
+}
+
+// forget removes the entry of key.
+func (c *contentCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// retain removes the entries whose key is not in keep.
+func (c *contentCache) retain(keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if !keep[key] {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package codeup
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContentCacheTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		ttl       time.Duration
+		advance   time.Duration
+		wantFetch int
+	}{
+		{"no ttl", "master:db/1_a.up.sql", 0, time.Hour, 1},
+		{"fresh", "master:db/1_a.up.sql", time.Minute, time.Second, 1},
+		{"expired", "master:db/1_a.up.sql", time.Minute, 2 * time.Minute, 2},
+		{"blob id kept", blobKeyPrefix + "e69de29", time.Minute, 2 * time.Minute, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			c := newContentCache(tt.ttl, clock)
+			fetches := 0
+			fetch := func() (string, error) {
+				fetches++
+				return "content", nil
+			}
+
+			if _, hit, _ := c.get(tt.key, fetch); hit {
+				t.Error("first get hit the cache")
+			}
+			clock.advance(tt.advance)
+			c.get(tt.key, fetch)
+			if fetches != tt.wantFetch {
+				t.Errorf("fetched %d times, want %d", fetches, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestContentCacheError(t *testing.T) {
+	c := newContentCache(0, realClock{})
+	fail := errors.New("fail")
+	if _, _, err := c.get("k", func() (string, error) { return "", fail }); err != fail {
+		t.Fatalf("err = %v, want %v", err, fail)
+	}
+	content, hit, err := c.get("k", func() (string, error) { return "ok", nil })
+	if err != nil || hit || content != "ok" {
+		t.Errorf("get after error = %q, %v, %v; want fetched again", content, hit, err)
+	}
+}
+
+func TestContentCacheRetain(t *testing.T) {
+	c := newContentCache(0, realClock{})
+	for _, key := range []string{"a", "b"} {
+		c.get(key, func() (string, error) { return key, nil })
+	}
+	c.retain(map[string]bool{"a": true})
+	if _, hit, _ := c.get("a", func() (string, error) { return "", nil }); !hit {
+		t.Error("retained entry a was evicted")
+	}
+	if _, hit, _ := c.get("b", func() (string, error) { return "", nil }); hit {
+		t.Error("entry b was retained")
+	}
+}
+
+// TestCacheTTLSharedBlob checks that an expired entry is not refetched by
+// the path of one file and then served for another file of the same blob.
+func TestCacheTTLSharedBlob(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"db/1_a.up.sql":   "CREATE TABLE a;",
+		"db/1_a.down.sql": "",
+		"db/2_b.up.sql":   "CREATE TABLE b;",
+		"db/2_b.down.sql": "",
+	})
+	clock := newFakeClock()
+	option := testOption("db")
+	option.CacheContents = true
+	option.CacheTTL = time.Minute
+	option.Clock = clock
+	d := newTestDriver(t, client, option)
+
+	if got := readDown(t, d, 2); got != "" {
+		t.Fatalf("ReadDown(2) = %q, want empty", got)
+	}
+	client.set("master", "db/2_b.down.sql", "DROP TABLE b;")
+	clock.advance(2 * time.Minute)
+	readDown(t, d, 2)
+	if got := readDown(t, d, 1); got != "" {
+		t.Errorf("ReadDown(1) = %q, want empty", got)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		advance time.Duration
+		want    bool
+	}{
+		{"no ttl", 0, time.Hour, true},
+		{"fresh", time.Minute, time.Second, true},
+		{"expired", time.Minute, 2 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			c := NewCacheTTL(tt.ttl, clock)
+			if _, ok := c.Get("k"); ok {
+				t.Fatal("Get of an empty cache found an entry")
+			}
+			c.Set("k", "a")
+			c.Set("k", "b")
+			if content, ok := c.Get("k"); !ok || content != "b" {
+				t.Errorf("Get = %q, %v; want b", content, ok)
+			}
+			clock.advance(tt.advance)
+			if content, ok := c.Get("k"); ok != tt.want || ok && content != "b" {
+				t.Errorf("Get after %v = %q, %v; want found %v", tt.advance, content, ok, tt.want)
+			}
+		})
+	}
+
+	if content, ok := NewCache().Get("k"); ok {
+		t.Errorf("NewCache().Get = %q, want nothing", content)
+	}
+}
+
+// TestNewCacheShared checks that two drivers sharing a NewCache
+// read a file once.
+func TestNewCacheShared(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	cache := NewCache()
+	option := testOption("db")
+	option.Cache = cache
+	d1 := newTestDriver(t, client, option)
+	d2 := newTestDriver(t, client, option)
+
+	for i, d := range []*CodeUp{d1, d2, d1} {
+		if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+			t.Fatalf("read %d: ReadUp(1) = %q", i, got)
+		}
+	}
+	if n := client.count("GetFileBlobs"); n != 1 {
+		t.Errorf("GetFileBlobs called %d times, want 1", n)
+	}
+	if content, ok := cache.Get("org/project/" + blobKeyPrefix + fakeBlobId("CREATE TABLE a;")); !ok || content != "CREATE TABLE a;" {
+		t.Errorf("cache entry = %q, %v", content, ok)
+	}
+}
+
+type mapCache map[string]string
+
+func (c mapCache) Get(key string) (string, bool) {
+	content, ok := c[key]
+	return content, ok
+}
+
+func (c mapCache) Set(key, content string) { c[key] = content }
+
+func TestSharedCache(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	cache := mapCache{}
+	for i := 0; i < 2; i++ {
+		option := testOption("db")
+		option.Cache = cache
+		d := newTestDriver(t, client, option)
+		if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+			t.Fatalf("ReadUp(1) = %q", got)
+		}
+	}
+	if n := client.count("GetFileBlobs"); n != 1 {
+		t.Errorf("GetFileBlobs called %d times, want 1", n)
+	}
+	want := "org/project/" + blobKeyPrefix + fakeBlobId("CREATE TABLE a;")
+	if _, ok := cache[want]; !ok {
+		t.Errorf("cache keys = %v, want %s", cache, want)
+	}
+}
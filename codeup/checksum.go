@@ -0,0 +1,57 @@
+package codeup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// checksum returns the hex encoded SHA-256 of content.
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks content of m against Option.Checksums.
+func (s *CodeUp) verify(m *source.Migration, content string) error {
+	if m.Direction != source.Up {
+		return nil
+	}
+	want, ok := s.option.Checksums[m.Version]
+	if !ok {
+		return nil
+	}
+	if got := checksum(content); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: %s has sha256 %s, want %s", ErrChecksumMismatch, m.Raw, got, want)
+	}
+	return nil
+}
+
+// Checksum returns the hex encoded SHA-256 of the migration body of version
+// in direction, as read before Option.Transform.
+func (s *CodeUp) Checksum(version uint, direction source.Direction) (string, error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return "", err
+	}
+
+	m := byVersion(ms, version, direction)
+	if m == nil {
+		return "", &fs.PathError{
+			Op:   "checksum version " + strconv.FormatUint(uint64(version), 10),
+			Path: s.option.Config.Path,
+			Err:  fs.ErrNotExist,
+		}
+	}
+
+	content, err := s.body(s.ctx, m)
+	if err != nil {
+		return "", err
+	}
+	return checksum(content), nil
+}
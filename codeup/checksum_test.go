@@ -0,0 +1,51 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestChecksum(t *testing.T) {
+	if got, want := checksum(""), "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; got != want {
+		t.Errorf("checksum(\"\") = %s, want %s", got, want)
+	}
+
+	option := testOption("db")
+	option.Transform = func(version uint, direction source.Direction, body []byte) ([]byte, error) {
+		return []byte("transformed"), nil
+	}
+	d := newTestDriver(t, newFakeClient(sampleFiles), option)
+	got, err := d.Checksum(1, source.Up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := checksum("CREATE TABLE a;"); got != want {
+		t.Errorf("Checksum(1) = %s, want the checksum before Transform %s", got, want)
+	}
+	if _, err := d.Checksum(3, source.Down); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Checksum(3, down): err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	option := testOption("db")
+	option.Checksums = map[uint]string{
+		1: strings.ToUpper(checksum("CREATE TABLE a;")),
+		2: checksum("something else"),
+	}
+	d := newTestDriver(t, newFakeClient(sampleFiles), option)
+
+	if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+		t.Errorf("ReadUp(1) = %q", got)
+	}
+	if _, _, err := d.ReadUp(2); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("ReadUp(2): err = %v, want ErrChecksumMismatch", err)
+	}
+	// Down migrations and versions without a checksum are not verified.
+	readDown(t, d, 2)
+	readUp(t, d, 3)
+}
@@ -0,0 +1,260 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	openapiutil "github.com/alibabacloud-go/openapi-util/service"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// Client is the subset of devops.Client used by the driver.
+// *devops.Client implements it; other implementations, like mocks,
+// may be passed to WithInstance. The driver also uses the ref, commit and paged
+// listing methods of devops.Client when the client has them.
+type Client interface {
+	ListRepositoryTreeWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.ListRepositoryTreeResponse, error)
+	GetFileBlobsWithOptions(repositoryId *string, request *devops.GetFileBlobsRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error)
+}
+
+// newAPIClient wraps client with the capabilities configured in option.
+// Clients other than *devops.Client are used as is.
+func newAPIClient(client Client, option Option) (Client, error) {
+	dc, ok := client.(*devops.Client)
+	if !ok {
+		if option.Credentials != nil || option.PathPrefix != "" || option.Compress {
+			return nil, errors.New("Option.Credentials, Option.PathPrefix and Option.Compress need a *devops.Client")
+		}
+		return client, nil
+	}
+
+	prefix := option.PathPrefix
+	var c Client = pagedClient{Client: dc, prefix: prefix, compress: option.Compress}
+	if option.Credentials != nil {
+		c = &refreshingClient{client: dc, provider: option.Credentials, prefix: prefix, compress: option.Compress}
+	}
+	if prefix != "" {
+		return gatewayClient{c.(gatewayAPI)}, nil
+	}
+	return c, nil
+}
+
+// treePager is implemented by clients that can list a repository tree page by page.
+//
+// A page is requested by token if the previous page returned one,
+// or else by page number.
+type treePager interface {
+	ListRepositoryTreePageWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, page, pageSize int, token string, headers map[string]*string, runtime *service.RuntimeOptions) (*treePage, error)
+}
+
+// treePage is a page of a tree listing.
+type treePage struct {
+	*devops.ListRepositoryTreeResponse
+	nextToken string // token of the next page, if the server pages by token.
+}
+
+// pagedClient adds tree pagination to devops.Client.
+//
+// devops.ListRepositoryTreeRequest has no page fields,
+// so the paged call is sent through CallApi directly.
+type pagedClient struct {
+	*devops.Client
+	prefix   string // path prefix of the tree and blob calls, see Option.PathPrefix.
+	compress bool   // ask for compressed blobs, see Option.Compress.
+}
+
+func (c pagedClient) ListRepositoryTreePageWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, page, pageSize int, token string, headers map[string]*string, runtime *service.RuntimeOptions) (*treePage, error) {
+	query := treeQuery(request)
+	query["page"] = tea.String(strconv.Itoa(page))
+	query["pageSize"] = tea.String(strconv.Itoa(pageSize))
+	if token != "" {
+		query["nextToken"] = tea.String(token)
+	}
+
+	result := &devops.ListRepositoryTreeResponse{}
+	body, err := c.CallApi(c.treeParams(repositoryId), &openapi.OpenApiRequest{Headers: headers, Query: query}, runtime)
+	if err != nil {
+		return &treePage{ListRepositoryTreeResponse: result}, err
+	}
+	err = tea.Convert(body, &result)
+	return &treePage{ListRepositoryTreeResponse: result, nextToken: nextToken(body)}, err
+}
+
+// ListRepositoryTreeWithOptions is devops.Client.ListRepositoryTreeWithOptions
+// sent under the path prefix, if any.
+func (c pagedClient) ListRepositoryTreeWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.ListRepositoryTreeResponse, error) {
+	if c.prefix == "" {
+		return c.Client.ListRepositoryTreeWithOptions(repositoryId, request, headers, runtime)
+	}
+
+	result := &devops.ListRepositoryTreeResponse{}
+	body, err := c.CallApi(c.treeParams(repositoryId), &openapi.OpenApiRequest{Headers: headers, Query: treeQuery(request)}, runtime)
+	if err != nil {
+		return result, err
+	}
+	err = tea.Convert(body, &result)
+	return result, err
+}
+
+// treeQuery returns the query parameters of request.
+func treeQuery(request *devops.ListRepositoryTreeRequest) map[string]*string {
+	query := map[string]*string{}
+	if request.AccessToken != nil {
+		query["accessToken"] = request.AccessToken
+	}
+	if request.OrganizationId != nil {
+		query["organizationId"] = request.OrganizationId
+	}
+	if request.Path != nil {
+		query["path"] = request.Path
+	}
+	if request.RefName != nil {
+		query["refName"] = request.RefName
+	}
+	if request.Type != nil {
+		query["type"] = request.Type
+	}
+	return query
+}
+
+// treeParams returns the params of a ListRepositoryTree call under the path prefix.
+func (c pagedClient) treeParams(repositoryId *string) *openapi.Params {
+	return &openapi.Params{
+		Action:      tea.String("ListRepositoryTree"),
+		Version:     tea.String("2021-06-25"),
+		Protocol:    tea.String("HTTPS"),
+		Pathname:    tea.String(c.prefix + "/repository/" + tea.StringValue(openapiutil.GetEncodeParam(repositoryId)) + "/files/tree"),
+		Method:      tea.String("GET"),
+		AuthType:    tea.String("AK"),
+		Style:       tea.String("ROA"),
+		ReqBodyType: tea.String("json"),
+		BodyType:    tea.String("json"),
+	}
+}
+
+// GetFileBlobsWithOptions is devops.Client.GetFileBlobsWithOptions
+// sent under the path prefix, if any, and compressed if asked to.
+func (c pagedClient) GetFileBlobsWithOptions(repositoryId *string, request *devops.GetFileBlobsRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error) {
+	if c.prefix == "" && !c.compress {
+		return c.Client.GetFileBlobsWithOptions(repositoryId, request, headers, runtime)
+	}
+
+	query := map[string]*string{}
+	if request.AccessToken != nil {
+		query["accessToken"] = request.AccessToken
+	}
+	if request.FilePath != nil {
+		query["filePath"] = request.FilePath
+	}
+	if request.OrganizationId != nil {
+		query["organizationId"] = request.OrganizationId
+	}
+	if request.Ref != nil {
+		query["ref"] = request.Ref
+	}
+
+	req := &openapi.OpenApiRequest{
+		Headers: headers,
+		Query:   query,
+	}
+	params := &openapi.Params{
+		Action:      tea.String("GetFileBlobs"),
+		Version:     tea.String("2021-06-25"),
+		Protocol:    tea.String("HTTPS"),
+		Pathname:    tea.String(c.prefix + "/repository/" + tea.StringValue(openapiutil.GetEncodeParam(repositoryId)) + "/files/blobs"),
+		Method:      tea.String("GET"),
+		AuthType:    tea.String("AK"),
+		Style:       tea.String("ROA"),
+		ReqBodyType: tea.String("json"),
+		BodyType:    tea.String("json"),
+	}
+	if c.compress {
+		return c.getCompressedBlob(params, headers, query, runtime)
+	}
+
+	result := &devops.GetFileBlobsResponse{}
+	body, err := c.CallApi(params, req, runtime)
+	if err != nil {
+		return result, err
+	}
+	err = tea.Convert(body, &result)
+	return result, err
+}
+
+// gatewayAPI is the part of the clients that sends calls under a path prefix.
+type gatewayAPI interface {
+	Client
+	treePager
+}
+
+// gatewayClient hides every call of a client but those sent under
+// its path prefix, so the driver skips the ref check and commit lookups
+// instead of sending them to the wrong path.
+type gatewayClient struct {
+	gatewayAPI
+}
+
+// nextToken returns the token of the next page in the response of CallApi.
+// The devops response types have no field for it.
+func nextToken(response map[string]interface{}) string {
+	body, _ := response["body"].(map[string]interface{})
+	for _, key := range []string{"nextToken", "NextToken"} {
+		if token, ok := body[key].(string); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// callAPI calls f, which sends the API action, for s.
+// It waits for the rate limiter first.
+func callAPI[T any](ctx context.Context, s *CodeUp, action string, f func() (T, error)) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.option.Metrics != nil {
+		s.option.Metrics.IncAPICall(action)
+	}
+	if s.limiter != nil {
+		if err := s.limiter.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	return withContext(ctx, f)
+}
+
+// withContext calls f and returns ctx.Err() if ctx is done before f returns.
+//
+// The devops client has no context support, so f itself is not interrupted;
+// it finishes in the background and its result is discarded.
+func withContext[T any](ctx context.Context, f func() (T, error)) (T, error) {
+	var zero T
+	if ctx == nil {
+		return f()
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := f()
+		ch <- result{v, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case r := <-ch:
+		return r.v, r.err
+	}
+}
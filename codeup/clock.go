@@ -0,0 +1,27 @@
+package codeup
+
+import "time"
+
+// Clock tells the time to the driver and waits for it.
+// Tests may set Option.Clock to advance time without waiting.
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock returns Option.Clock, or the real clock if it is not set.
+func (s *CodeUp) clock() Clock {
+	if s.option.Clock != nil {
+		return s.option.Clock
+	}
+	return realClock{}
+}
@@ -2,7 +2,10 @@
 package codeup
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	iurl "net/url"
@@ -10,6 +13,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
 	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
@@ -19,16 +24,170 @@ import (
 )
 
 func init() {
-	source.Register("codeup", CodeUp{})
+	source.Register("codeup", &CodeUp{})
 }
 
 // Option is the configuration setting for the CodeUp driver.
 type Option struct {
-	Config  Config
+	Config Config
+
+	// Headers are sent with each API call.
+	//
+	// Responses are already gzip compressed when the server supports it:
+	// the HTTP transport asks for gzip and decompresses transparently.
+	// Setting Accept-Encoding here turns that off, and the devops client
+	// can not decode compressed responses itself; use Compress instead.
 	Headers map[string]*string
+
 	Runtime *service.RuntimeOptions
+
+	// HeaderProvider, if set, is called before each API call.
+	// The headers it returns are sent on top of Headers.
+	HeaderProvider func() map[string]*string
+
+	// PathPrefix, if set, is prepended to the request paths of the tree
+	// listing and blob calls, like "/codeup", for CodeUp behind an API gateway.
+	// Branch, tag and commit lookups can not be sent under it and are skipped.
+	PathPrefix string
+
+	// Transport, if set, overrides the transport settings of Runtime.
+	Transport *Transport
+
+	// Compress asks for file contents compressed with gzip or deflate
+	// and decompresses them. Uncompressed responses are read as usual.
+	// It needs a *devops.Client.
+	Compress bool
+
+	// UpOnly makes loading fail if any down migration is found.
+	// Up-only sources return ErrNoDown from ReadDown.
+	UpOnly bool
+
+	// RequireMigrations makes loading fail with ErrNoMigrations
+	// if no migration is found.
+	RequireMigrations bool
+
+	// LenientParse skips files whose names can not be parsed
+	// instead of failing to load.
+	LenientParse bool
+
+	// SkipRefCheck disables checking that Config.Ref exists before loading,
+	// which takes one to three calls. The URL parameter is skipRefCheck.
+	SkipRefCheck bool
+
+	// ValidateSequence makes loading fail if versions have gaps or duplicates.
+	ValidateSequence bool
+
+	// CheckIdentifiers makes loading fail if the up and down migrations
+	// of a version have different identifiers.
+	CheckIdentifiers bool
+
+	// RequirePairs makes loading fail if any version
+	// misses its up or down migration.
+	RequirePairs bool
+
+	// CacheContents keeps file contents in memory after the first read.
+	CacheContents bool
+
+	// Cache, if set, is a content cache shared with other drivers,
+	// checked after the own cache of CacheContents, like one of
+	// NewCache or NewCacheTTL.
+	Cache Cache
+
+	// CacheTTL, if positive, is how long contents cached by CacheContents
+	// or Prefetch are kept before they are read again. Contents cached by
+	// blob id never change and are kept regardless.
+	CacheTTL time.Duration
+
+	// RefreshInterval, if positive, makes the driver Reload in the background
+	// at this interval until it is closed. Failed reloads keep the loaded migrations.
+	RefreshInterval time.Duration
+
+	// CacheDir, if set, is a directory where file contents are kept
+	// across runs, keyed by blob id, so unchanged files are not downloaded again.
+	CacheDir string
+
+	// Prefetch reads every migration body into memory when the driver is created,
+	// and again after each Reload. A body that fails to be read fails Open.
+	Prefetch        bool
+	PrefetchWorkers int // concurrent reads of Prefetch, default is DefaultPrefetchWorkers.
+
+	// PartialPrefetch makes a failed Prefetch of Open keep the driver:
+	// failed reads are logged, the bodies read are kept, and the others
+	// are read when needed, or by the next Reload or Prefetch.
+	PartialPrefetch bool
+
+	// MaxConcurrency is the maximum number of directories listed at once
+	// by a Recursive or VersionDirs load, default is DefaultMaxConcurrency.
+	MaxConcurrency int
+
+	// RateLimit, if positive, is the maximum number of API calls per second.
+	// Calls over the limit wait instead of failing.
+	RateLimit float64
+	RateBurst int // calls allowed at once under RateLimit, default is 1.
+
+	// ReadTimeout, if positive, limits each file read, plus a small random jitter.
+	// Reads over the limit fail with ErrTimeout.
+	ReadTimeout time.Duration
+
+	// RefRetries is the number of times a read is retried
+	// while its ref is not found, Option.RefRetryDelay apart.
+	RefRetries    int
+	RefRetryDelay time.Duration // default is DefaultRefRetryDelay.
+
+	// MaxSize, if positive, is the largest file in bytes that is read.
+	// Larger files fail with ErrTooLarge.
+	//
+	// The API returns whole file contents only, so bodies are always
+	// held in memory; MaxSize bounds how much.
+	MaxSize int64
+
+	// MaxMigrations, if positive, is the most migration files loading may find,
+	// a guard against pointing the driver at a huge directory.
+	// Loading more fails with ErrTooManyMigrations.
+	MaxMigrations int
+
+	// Checksums, if set, maps versions to the hex encoded SHA-256
+	// of their up migration body. Reading a body that does not match
+	// fails with ErrChecksumMismatch.
+	Checksums map[uint]string
+
+	// Parser, if set, parses file names instead of source.Parse
+	// or Config.Extensions.
+	Parser Parser
+
+	// PathRewrite, if set, rewrites the repo path of each file before it is
+	// fetched, like swapping a prefix in a mirror whose listing and blob
+	// paths differ. It gets the path joined from Config.BlobBasePath.
+	PathRewrite func(filePath string) string
+
+	// Transform, if set, rewrites each migration body before it is returned.
+	Transform func(version uint, direction source.Direction, body []byte) ([]byte, error)
+
+	// Clock, if set, replaces the real clock in retries, rate limiting,
+	// cache expiry and background refreshes. Context deadlines
+	// are still on the real clock.
+	Clock Clock
+
+	// Logger, if set, receives an Event for each API call and skipped file.
+	Logger Logger
+
+	// ClientBuilder, if set, creates the devops client of Open.
+	ClientBuilder ClientBuilder
+
+	// Metrics, if set, counts API calls, cache hits and errors.
+	Metrics Metrics
+
+	// Tracer, if set, starts a span around each tree listing and file read.
+	Tracer Tracer
+
+	// Credentials, if set, renews the client credentials
+	// when a call fails because the security token expired.
+	Credentials CredentialProvider
 }
 
+// ClientBuilder creates a devops client from the config derived from the URL.
+type ClientBuilder func(config *openapi.Config) (*devops.Client, error)
+
 // NewOption creates a new Option.
 func NewOption(c Config) Option {
 	return Option{
@@ -43,28 +202,322 @@ type Config struct {
 	ProjectId      string
 	OrganizationId string
 	AccessToken    string
-	Path           string // repo path
-	Ref            string // repo ref, default is "master".
+
+	// PersonalAccessToken, if set, is sent in the PersonalAccessTokenHeader
+	// header of each call, for organizations that disable AccessToken.
+	PersonalAccessToken string
+
+	Path           string   // repo path, relative to the repo root without leading or trailing slashes.
+	Paths          []string // directories under Path to read, default is Path itself.
+	PathGlob       string   // pattern of more directories under Path to read, like "*/sql".
+	ListPath       string   // path to list migrations from, default is Path.
+	BlobBasePath   string   // path that file names are joined to when read, default is Path.
+	Ref            string   // repo ref (branch, tag or commit id), default is DefaultRef.
+	Refs           []string // more refs to read migrations from, each version may be found at one ref only.
+	ActiveRef      string   // if set, only the migrations of this ref of Ref and Refs are used.
+	RefKind        string   // kind of Ref, one of RefBranch, RefTag and RefCommit, or "" if unknown.
+	FallbackRef    string   // ref to read files from when Ref is not found.
+	ListRef        string   // ref to list migrations at instead of Ref.
+	ReadRef        string   // ref to read the files listed at ListRef or Ref from.
+	PageSize       int      // tree listing page size, default is DefaultPageSize.
+	Recursive      bool     // also read migrations from subdirectories of Path.
+	Filter         string   // only read files whose name has this prefix or matches this glob.
+	StripPrefix    string   // prefix removed from file names before they are parsed, like "migration_".
+	NormalizeNames bool     // lowercase the direction and extension of file names before they are parsed.
+	SingleFile     bool     // Path is a single migration file, which is read without listing.
+	MinVersion     uint     // only read versions from MinVersion.
+	MaxVersion     uint     // only read versions up to MaxVersion, if set.
+	VersionDirs    bool     // each migration is a directory "{version}_{title}" with up and down files.
+	Manifest       string   // JSON file under Path listing the migrations, which is read instead of listing.
+	Archive        bool     // Path is a zip, tar or gzip compressed tar file of migrations, which is read once, from BlobBasePath if set.
+	Lockfile       string   // JSON file under Path pinning each version to the commit it is read at.
+
+	IncludeVersions []uint // only read these versions, if set.
+	ExcludeVersions []uint // never read these versions.
+
+	// TimestampVersions checks that versions are timestamps in TimestampLayout,
+	// like "20240101120000", that fit in uint.
+	TimestampVersions bool
+
+	// Extensions maps file name suffixes, like ".up.ddl", to directions.
+	// If set, only files with one of the suffixes are read,
+	// instead of any file accepted by source.Parse.
+	Extensions map[string]source.Direction
+}
+
+// validate returns an error naming the first required field that is empty.
+func (c Config) validate() error {
+	if c.ProjectId == "" {
+		return errors.New("missing ProjectId")
+	}
+	if c.OrganizationId == "" {
+		return errors.New("missing OrganizationId")
+	}
+	if _, err := path.Match(c.PathGlob, ""); err != nil {
+		return fmt.Errorf("invalid PathGlob %q: %w", c.PathGlob, err)
+	}
+	if c.ActiveRef != "" && !hasRef(c.refs(), c.ActiveRef) {
+		return fmt.Errorf("ActiveRef %q is not one of the refs read", c.ActiveRef)
+	}
+	return nil
+}
+
+func hasRef(refs []string, ref string) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether version is between MinVersion and MaxVersion.
+func (c Config) inRange(version uint) bool {
+	return version >= c.MinVersion && (c.MaxVersion == 0 || version <= c.MaxVersion)
+}
+
+// allowed reports whether version passes IncludeVersions and ExcludeVersions.
+func (c Config) allowed(version uint) bool {
+	if len(c.IncludeVersions) != 0 && !hasVersion(c.IncludeVersions, version) {
+		return false
+	}
+	return !hasVersion(c.ExcludeVersions, version)
+}
+
+func hasVersion(versions []uint, version uint) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// refs returns the refs to list migrations at,
+// ListRef or else Ref followed by Refs.
+func (c Config) refs() []string {
+	return append([]string{c.listRef()}, c.Refs...)
+}
+
+func (c Config) listRef() string {
+	if c.ListRef != "" {
+		return c.ListRef
+	}
+	return c.Ref
+}
+
+// readRef returns the ref to read the files listed at ref from.
+func (c Config) readRef(ref string) string {
+	if c.ReadRef != "" && ref == c.listRef() {
+		return c.ReadRef
+	}
+	return ref
+}
+
+func (c Config) listPath() string {
+	if c.ListPath != "" {
+		return c.ListPath
+	}
+	return c.Path
+}
+
+func (c Config) blobBasePath() string {
+	if c.BlobBasePath != "" {
+		return c.BlobBasePath
+	}
+	if c.SingleFile || c.Archive {
+		return path.Dir(c.Path)
+	}
+	return c.Path
 }
 
-func configFromUrl(url *iurl.URL) Config {
+// PersonalAccessTokenHeader is the header carrying Config.PersonalAccessToken.
+//
+// The Authorization header can not be used: it carries the request
+// signature of the devops client.
+const PersonalAccessTokenHeader = "x-yunxiao-token"
+
+// DefaultPageSize is the page size used to list the repo tree
+// when Config.PageSize is not set.
+const DefaultPageSize = 100
+
+// DefaultMaxConcurrency is the number of directories listed at once
+// when Option.MaxConcurrency is not set.
+const DefaultMaxConcurrency = 4
+
+// DefaultRef is the ref used when the URL names none.
+var DefaultRef = "master"
+
+func configFromUrl(url *iurl.URL) (Config, error) {
 	ref := url.Fragment
 	if ref == "" {
-		ref = "master"
+		ref = DefaultRef
 	}
 
 	query := url.Query()
+	var kind string
+	// Explicit refs take precedence over the fragment: branch, tag or commit, then ref.
+	// url.Parse has unescaped the fragment already, so refs with slashes
+	// like "release/2024.1" may be given either way.
+	var kinds []string
+	for _, key := range []string{"branch", "tag", "commitId"} {
+		if query.Get(key) != "" {
+			kinds = append(kinds, key)
+		}
+	}
+	if len(kinds) > 1 {
+		return Config{}, fmt.Errorf("only one of branch, tag and commitId may be set, got %s", strings.Join(kinds, " and "))
+	}
+	if v := query.Get("ref"); v != "" {
+		ref = v
+	}
+	if v := query.Get("branch"); v != "" {
+		ref, kind = v, RefBranch
+	}
+	if v := query.Get("tag"); v != "" {
+		ref, kind = v, RefTag
+	}
+	if id := query.Get("commitId"); id != "" {
+		if !isCommitId(id) {
+			return Config{}, fmt.Errorf("invalid commit id %q", id)
+		}
+		ref, kind = id, RefCommit
+	}
+	if ref == "" {
+		return Config{}, errors.New("no ref in URL and DefaultRef is empty")
+	}
+
+	// url.Parse has unescaped the path already, so a path with spaces
+	// or unicode like "db%20migrations" is read as "db migrations".
+	// The devops client escapes file paths again in the request query.
 	c := Config{
 		ProjectId:      query.Get("projectId"),
 		OrganizationId: query.Get("organizationId"),
 		AccessToken:    query.Get("accessToken"),
-		Path:           url.Path,
+		Path:           cleanPath(url.Path),
 		Ref:            ref,
+		RefKind:        kind,
+		FallbackRef:    query.Get("fallbackRef"),
+		ListRef:        query.Get("listRef"),
+		ReadRef:        query.Get("readRef"),
+		ActiveRef:      query.Get("activeRef"),
+		ListPath:       cleanPath(query.Get("listPath")),
+		BlobBasePath:   cleanPath(query.Get("blobBasePath")),
+		Filter:         query.Get("filter"),
+		StripPrefix:    query.Get("stripPrefix"),
+		Manifest:       cleanPath(query.Get("manifest")),
+		Lockfile:       cleanPath(query.Get("lockfile")),
 	}
-	return c
+	c.PersonalAccessToken = query.Get("personalAccessToken")
+	if v := query.Get("singleFile"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.SingleFile = b
+	}
+	if v := query.Get("normalizeNames"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.NormalizeNames = b
+	}
+	if v := query.Get("timestampVersions"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.TimestampVersions = b
+	}
+	if v := query.Get("archive"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.Archive = b
+	}
+	if v := query.Get("versionDirs"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.VersionDirs = b
+	}
+	if v := query.Get("refs"); v != "" {
+		c.Refs = strings.Split(v, ",")
+	}
+	c.PathGlob = query.Get("pathGlob")
+	if v := query.Get("paths"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			c.Paths = append(c.Paths, cleanPath(p))
+		}
+	}
+	if v := query.Get("recursive"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.Recursive = b
+	}
+	for name, version := range map[string]*uint{
+		"minVersion": &c.MinVersion,
+		"maxVersion": &c.MaxVersion,
+	} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*version = uint(n)
+		}
+	}
+	for name, versions := range map[string]*[]uint{
+		"includeVersions": &c.IncludeVersions,
+		"excludeVersions": &c.ExcludeVersions,
+	} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		for _, f := range strings.Split(v, ",") {
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*versions = append(*versions, uint(n))
+		}
+	}
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, err
+		}
+		c.PageSize = n
+	}
+	return c, nil
 }
 
-func clientConfigFromUrl(u *iurl.URL) *openapi.Config {
+// cleanPath returns p in the canonical form of Config paths:
+// duplicate slashes collapsed and leading and trailing slashes removed.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// isCommitId reports whether s looks like a full or abbreviated commit SHA.
+func isCommitId(s string) bool {
+	if len(s) < 7 || len(s) > 40 {
+		return false
+	}
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func clientConfigFromUrl(u *iurl.URL) (*openapi.Config, error) {
 	key := u.User.Username()
 	if key == "" {
 		key = os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_ID")
@@ -73,69 +526,291 @@ func clientConfigFromUrl(u *iurl.URL) *openapi.Config {
 	if !ok {
 		secret = os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET")
 	}
+	token := u.Query().Get("securityToken")
+	if token == "" {
+		token = os.Getenv("ALIBABA_CLOUD_SECURITY_TOKEN")
+	}
 
-	return &openapi.Config{
+	c := &openapi.Config{
 		AccessKeyId:     tea.String(key),
 		AccessKeySecret: tea.String(secret),
 		Endpoint:        tea.String(u.Host),
 	}
+	if token != "" {
+		c.SecurityToken = tea.String(token)
+	}
+	if arn := u.Query().Get("roleArn"); arn != "" {
+		cred, err := roleCredential(key, secret, arn, u.Query().Get("roleSessionName"))
+		if err != nil {
+			return nil, err
+		}
+		// The client uses the access key instead of Credential if it is set.
+		c.AccessKeyId, c.AccessKeySecret, c.SecurityToken = nil, nil, nil
+		c.Credential = cred
+	}
+	network := u.Query().Get("network")
+	if region := u.Query().Get("region"); region != "" {
+		endpoint, err := NetworkEndpoint(region, network)
+		if err != nil {
+			return nil, err
+		}
+		c.RegionId = tea.String(region)
+		c.Endpoint = tea.String(endpoint)
+	} else if network != "" {
+		return nil, errors.New("network needs a region")
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		c.Endpoint = tea.String(endpoint)
+	}
+	return c, nil
 }
 
 // CodeUp implements source.Driver for CodeUp.
+//
+// Only *CodeUp implements source.Driver: its methods have pointer receivers
+// as it holds a mutex, so a CodeUp value must not be used or copied.
+// Code passing codeup.CodeUp{} as a driver must pass &codeup.CodeUp{}.
 type CodeUp struct {
+	inFlight   int64 // bodies not closed yet, accessed atomically; first for 64-bit alignment.
+	ctx        context.Context
 	option     Option
-	client     *devops.Client
+	client     Client
 	migrations *source.Migrations
+	files      map[string]*file    // loaded files by raw name.
+	duplicates []*source.Migration // loaded files with the version and direction of another.
+	cache      *contentCache       // nil if contents are not cached.
+	limiter    *limiter            // nil if calls are not rate limited.
+	listing    chan struct{}       // bounds concurrent listings, nil if unbounded.
+
+	mu     sync.RWMutex // guards migrations, files, duplicates and closed.
+	closed bool
+	stop   chan struct{} // closed by Close.
+
+	refreshing sync.WaitGroup // the goroutine of Option.RefreshInterval, waited for by Close.
 }
 
 // WithInstance returns a new CodeUp driver instance configured with parameters
-func WithInstance(client *devops.Client, option Option) (source.Driver, error) {
+func WithInstance(client Client, option Option) (source.Driver, error) {
+	return WithInstanceContext(context.Background(), client, option)
+}
+
+// WithInstanceContext is like WithInstance but uses ctx for
+// the directory listing and every subsequent read of the driver.
+func WithInstanceContext(ctx context.Context, client Client, option Option) (source.Driver, error) {
+	if err := option.Config.validate(); err != nil {
+		return nil, err
+	}
+	if dc, ok := client.(*devops.Client); ok && option.Config.AccessToken == "" && option.Config.PersonalAccessToken == "" && dc.Credential == nil {
+		return nil, errors.New("missing AccessToken, PersonalAccessToken or access key")
+	}
+	c, err := newAPIClient(client, option)
+	if err != nil {
+		return nil, err
+	}
+
+	if option.Transport != nil {
+		runtime := new(service.RuntimeOptions)
+		if option.Runtime != nil {
+			*runtime = *option.Runtime
+		}
+		option.Transport.apply(runtime)
+		option.Runtime = runtime
+	}
+
 	gn := &CodeUp{
+		ctx:        ctx,
 		option:     option,
-		client:     client,
+		client:     c,
 		migrations: source.NewMigrations(),
+		files:      make(map[string]*file),
+		stop:       make(chan struct{}),
+	}
+	maxConcurrency := option.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	gn.listing = make(chan struct{}, maxConcurrency)
+	if option.RateLimit > 0 {
+		gn.limiter = newLimiter(option.RateLimit, option.RateBurst, gn.clock())
+	}
+	if option.CacheContents || option.Prefetch {
+		gn.cache = newContentCache(option.CacheTTL, gn.clock())
 	}
 
-	err := gn.readDirectory()
-	if err != nil {
+	if !option.SkipRefCheck {
+		if err := gn.checkRef(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := gn.readDirectory(ctx); err != nil {
 		return nil, err
 	}
+	if _, ok := gn.migrations.First(); !ok && option.RequireMigrations {
+		return nil, &fs.PathError{Op: "load", Path: option.Config.Path, Err: ErrNoMigrations}
+	}
+	if option.Prefetch {
+		if err := gn.prefetch(ctx); err != nil && !option.PartialPrefetch {
+			return nil, err
+		}
+	}
+	if option.RefreshInterval > 0 {
+		gn.refreshing.Add(1)
+		go func() {
+			defer gn.refreshing.Done()
+			gn.refresh(option.RefreshInterval)
+		}()
+	}
 	return gn, nil
 }
 
+// WithClientBuilder returns a driver whose Open creates the devops client with builder.
+// Call Open on it directly or register it with source.Register under another name.
+func WithClientBuilder(builder ClientBuilder) *CodeUp {
+	return &CodeUp{option: Option{ClientBuilder: builder}}
+}
+
 // Open returns a new driver instance configured with parameters
 // coming from the URL string. Migrate will call this function
 // only once per instance.
-func (s CodeUp) Open(url string) (source.Driver, error) {
+func (s *CodeUp) Open(url string) (source.Driver, error) {
+	return s.OpenWithContext(context.Background(), url)
+}
+
+// OpenWithContext is like Open but uses ctx for the directory
+// listing and every subsequent read of the driver.
+func (s *CodeUp) OpenWithContext(ctx context.Context, url string) (source.Driver, error) {
 	u, err := iurl.Parse(url)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := devops.NewClient(clientConfigFromUrl(u))
+	option, err := optionFromUrl(u)
 	if err != nil {
 		return nil, err
 	}
 
-	cn := CodeUp{
-		client:     client,
-		migrations: source.NewMigrations(),
-		option:     NewOption(configFromUrl(u)),
+	clientConfig, err := clientConfigFromUrl(u)
+	if err != nil {
+		return nil, err
 	}
 
-	err = cn.readDirectory()
+	option.Transport.proxyFromEnvironment(tea.StringValue(clientConfig.Endpoint))
+
+	build := s.option.ClientBuilder
+	if build == nil {
+		build = devops.NewClient
+	}
+	client, err := build(clientConfig)
 	if err != nil {
 		return nil, err
 	}
-	return cn, nil
+
+	option.ClientBuilder = s.option.ClientBuilder
+	return WithInstanceContext(ctx, client, option)
+}
+
+// WithURL returns a new driver instance configured from u like Open,
+// but using client instead of one created from the URL credentials.
+func WithURL(client Client, u *iurl.URL) (source.Driver, error) {
+	option, err := optionFromUrl(u)
+	if err != nil {
+		return nil, err
+	}
+	return WithInstance(client, option)
+}
+
+// optionFromUrl returns the Option of the driver configured by u.
+func optionFromUrl(u *iurl.URL) (Option, error) {
+	config, err := configFromUrl(u)
+	if err != nil {
+		return Option{}, err
+	}
+
+	transport, err := transportFromUrl(u)
+	if err != nil {
+		return Option{}, err
+	}
+
+	option := NewOption(config)
+	option.Transport = transport
+	if v := u.Query().Get("pathPrefix"); v != "" {
+		option.PathPrefix = "/" + cleanPath(v)
+	}
+	if v := u.Query().Get("skipRefCheck"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Option{}, err
+		}
+		option.SkipRefCheck = b
+	}
+	if v := u.Query().Get("compress"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Option{}, err
+		}
+		option.Compress = b
+	}
+	return option, nil
 }
 
 // Close closes the underlying source instance managed by the driver.
-func (s CodeUp) Close() error { return nil }
+//
+// It stops the background reloads of Option.RefreshInterval, waiting for
+// a reload in progress, and releases the loaded migrations and cached contents.
+// Reads after Close return ErrClosed.
+func (s *CodeUp) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.closed = true
+	if s.stop != nil {
+		close(s.stop)
+	}
+	s.migrations = source.NewMigrations()
+	s.files = make(map[string]*file)
+	s.duplicates = nil
+	s.mu.Unlock()
+
+	// The reload takes s.mu, and may fill the cache until it ends.
+	s.refreshing.Wait()
+	if s.cache != nil {
+		s.cache.clear()
+	}
+	return nil
+}
+
+// Healthcheck verifies that CodeUp can be reached with the configured
+// credentials by listing the migration directory once.
+func (s *CodeUp) Healthcheck(ctx context.Context) error {
+	if _, err := s.loaded(); err != nil {
+		return err
+	}
+	_, err := s.listOnce(ctx, s.treeRequest(s.option.Config.listRef(), s.option.Config.listPath()))
+	return err
+}
+
+// loaded returns the migrations of the driver, or ErrClosed after Close.
+func (s *CodeUp) loaded() (*source.Migrations, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	return s.migrations, nil
+}
 
 // First returns the very first migration version available to the driver.
-func (s CodeUp) First() (version uint, err error) {
-	v, ok := s.migrations.First()
+func (s *CodeUp) First() (version uint, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := ms.First()
 	if ok {
 		return v, nil
 	}
@@ -143,13 +818,39 @@ func (s CodeUp) First() (version uint, err error) {
 	return 0, &fs.PathError{
 		Op:   "first",
 		Path: s.option.Config.Path,
-		Err:  fs.ErrNotExist,
+		Err:  ErrNoMigrations,
+	}
+}
+
+// Last returns the very last migration version available to the driver.
+func (s *CodeUp) Last() (version uint, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := ms.First()
+	if !ok {
+		return 0, &fs.PathError{
+			Op:   "last",
+			Path: s.option.Config.Path,
+			Err:  ErrNoMigrations,
+		}
 	}
+	for next, found := ms.Next(v); found; next, found = ms.Next(v) {
+		v = next
+	}
+	return v, nil
 }
 
 // Prev returns the previous version for a given version available to the driver.
-func (s CodeUp) Prev(version uint) (prevVersion uint, err error) {
-	v, ok := s.migrations.Prev(version)
+func (s *CodeUp) Prev(version uint) (prevVersion uint, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := ms.Prev(version)
 	if ok {
 		return v, nil
 	}
@@ -162,8 +863,13 @@ func (s CodeUp) Prev(version uint) (prevVersion uint, err error) {
 }
 
 // Next returns the next version for a given version available to the driver.
-func (s CodeUp) Next(version uint) (nextVersion uint, err error) {
-	v, ok := s.migrations.Next(version)
+func (s *CodeUp) Next(version uint) (nextVersion uint, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := ms.Next(version)
 	if ok {
 		return v, nil
 	}
@@ -175,10 +881,91 @@ func (s CodeUp) Next(version uint) (nextVersion uint, err error) {
 	}
 }
 
+// Versions returns all migration versions available to the driver in ascending order.
+func (s *CodeUp) Versions() []uint {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil
+	}
+
+	var versions []uint
+	v, ok := ms.First()
+	for ok {
+		versions = append(versions, v)
+		v, ok = ms.Next(v)
+	}
+	return versions
+}
+
+// Has reports whether version is available, without any API call.
+func (s *CodeUp) Has(version uint) bool {
+	_, _, _, ok := s.Describe(version)
+	return ok
+}
+
+// Pending returns the versions greater than after in ascending order.
+func (s *CodeUp) Pending(after uint) []uint {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil
+	}
+
+	var versions []uint
+	v, ok := ms.First()
+	for ok {
+		if v > after {
+			versions = append(versions, v)
+		}
+		v, ok = ms.Next(v)
+	}
+	return versions
+}
+
+// Describe returns the identifier and the raw up and down file names of version
+// without reading their contents. ok is false if version is not available.
+func (s *CodeUp) Describe(version uint) (identifier, rawUp, rawDown string, ok bool) {
+	ms, err := s.loaded()
+	if err != nil {
+		return "", "", "", false
+	}
+
+	if m, found := ms.Down(version); found {
+		identifier, rawDown, ok = m.Identifier, m.Raw, true
+	}
+	if m, found := ms.Up(version); found {
+		identifier, rawUp, ok = m.Identifier, m.Raw, true
+	}
+	return identifier, rawUp, rawDown, ok
+}
+
+// RefOf returns the ref the migrations of version were read from.
+// ok is false if version is not available.
+func (s *CodeUp) RefOf(version uint) (ref string, ok bool) {
+	_, rawUp, rawDown, ok := s.Describe(version)
+	if !ok {
+		return "", false
+	}
+	if rawUp == "" {
+		rawUp = rawDown
+	}
+	return s.fileRef(rawUp), true
+}
+
 // ReadUp returns the UP migration body and an identifier that helps
 // finding this migration in the source for a given version.
-func (s CodeUp) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
-	m, ok := s.migrations.Up(version)
+func (s *CodeUp) ReadUp(version uint) (r io.ReadCloser, identifier string, err error) {
+	return s.ReadUpCtx(s.ctx, version)
+}
+
+// ReadUpCtx is like ReadUp but uses ctx instead of the context of the driver,
+// so a slow read can be cancelled.
+func (s *CodeUp) ReadUpCtx(ctx context.Context, version uint) (r io.ReadCloser, identifier string, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, ok := ms.Up(version)
 	if !ok {
 		return nil, "", &fs.PathError{
 			Op:   "read version " + strconv.FormatUint(uint64(version), 10),
@@ -187,88 +974,799 @@ func (s CodeUp) ReadUp(version uint) (r io.ReadCloser, identifier string, err er
 		}
 	}
 
-	content, err := s.read(m.Raw)
+	r, err = s.open(ctx, m)
 	if err != nil {
 		return nil, "", err
 	}
-
-	sr := strings.NewReader(content)
-	return io.NopCloser(sr), m.Identifier, nil
+	return r, m.Identifier, nil
 }
 
 // ReadDown returns the DOWN migration body and an identifier that helps
 // finding this migration in the source for a given version.
-func (s CodeUp) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
-	m, ok := s.migrations.Down(version)
+func (s *CodeUp) ReadDown(version uint) (r io.ReadCloser, identifier string, err error) {
+	return s.ReadDownCtx(s.ctx, version)
+}
+
+// ReadDownCtx is like ReadDown but uses ctx instead of the context of the driver,
+// so a slow read can be cancelled.
+func (s *CodeUp) ReadDownCtx(ctx context.Context, version uint) (r io.ReadCloser, identifier string, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, ok := ms.Down(version)
 	if !ok {
+		err = fs.ErrNotExist
+		if _, up := ms.Up(version); up {
+			err = ErrNoDown
+		}
 		return nil, "", &fs.PathError{
 			Op:   "read version " + strconv.FormatUint(uint64(version), 10),
 			Path: s.option.Config.Path,
-			Err:  fs.ErrNotExist,
+			Err:  err,
 		}
 	}
 
-	content, err := s.read(m.Raw)
+	r, err = s.open(ctx, m)
 	if err != nil {
 		return nil, "", err
 	}
+	return r, m.Identifier, nil
+}
+
+// open returns the body of m.
+func (s *CodeUp) open(ctx context.Context, m *source.Migration) (io.ReadCloser, error) {
+	content, err := s.body(s.withVersion(ctx, m.Version), m)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(m, content); err != nil {
+		return nil, err
+	}
 
-	sr := strings.NewReader(content)
-	return io.NopCloser(sr), m.Identifier, nil
+	if s.option.Transform == nil {
+		return s.newBodyReader(strings.NewReader(content)), nil
+	}
+	body, err := s.option.Transform(m.Version, m.Direction, []byte(content))
+	if err != nil {
+		return nil, err
+	}
+	return s.newBodyReader(bytes.NewReader(body)), nil
 }
 
-func (s CodeUp) readDirectory() error {
-	resp, err := s.client.ListRepositoryTreeWithOptions(
-		tea.String(s.option.Config.ProjectId),
-		&devops.ListRepositoryTreeRequest{
-			OrganizationId: tea.String(s.option.Config.OrganizationId),
-			AccessToken:    tea.String(s.option.Config.AccessToken),
-			Path:           tea.String(s.option.Config.Path),
-			RefName:        tea.String(s.option.Config.Ref),
-		},
-		s.option.Headers,
-		s.option.Runtime,
-	)
+// body returns the content of m, decompressed if it is gzip compressed.
+func (s *CodeUp) body(ctx context.Context, m *source.Migration) (string, error) {
+	content, err := s.read(ctx, m.Raw)
+	if err != nil {
+		return "", err
+	}
+	if isGzip(m.Raw, content) {
+		return s.gunzip(m.Raw, content)
+	}
+	return content, nil
+}
+
+// readDirectory loads the migrations of the driver,
+// replacing the loaded ones only if loading succeeds.
+func (s *CodeUp) readDirectory(ctx context.Context) error {
+	files, err := s.findFiles(ctx)
 	if err != nil {
 		return err
 	}
+	if s.option.Config.Lockfile != "" {
+		if err := s.pin(ctx, files); err != nil {
+			return err
+		}
+	}
+
+	ms := source.NewMigrations()
+	byRaw := make(map[string]*file)
+	var duplicates []*source.Migration
+	for _, f := range files {
+		if !s.option.Config.inRange(f.Version) {
+			s.log(Event{Op: OpSkip, Path: f.Raw, Reason: "out of version range"})
+			continue
+		}
+		if !s.option.Config.allowed(f.Version) {
+			s.log(Event{Op: OpSkip, Path: f.Raw, Reason: "version not allowed"})
+			continue
+		}
+		if ref := s.option.Config.ActiveRef; ref != "" && f.ref != ref {
+			s.log(Event{Op: OpSkip, Path: f.Raw, Ref: f.ref, Reason: "inactive ref"})
+			continue
+		}
+		if s.option.UpOnly && f.Direction == source.Down {
+			return fmt.Errorf("down migration %s in up-only source", f.Raw)
+		}
+		if !ms.Append(f.Migration) {
+			if other := byVersion(ms, f.Version, f.Direction); strings.EqualFold(other.Raw, f.Raw) {
+				return fmt.Errorf("migrations %s and %s differ only in case", other.Raw, f.Raw)
+			}
+			duplicates = append(duplicates, f.Migration)
+			continue
+		}
+		byRaw[f.Raw] = f
+		if max := s.option.MaxMigrations; max > 0 && len(byRaw) > max {
+			return fmt.Errorf("%w: more than %d migration files in %s", ErrTooManyMigrations, max, s.option.Config.Path)
+		}
+	}
+
+	if s.option.ValidateSequence {
+		if err := validateSequence(ms, duplicates); err != nil {
+			return err
+		}
+	}
+	if s.option.CheckIdentifiers {
+		if err := validateIdentifiers(ms); err != nil {
+			return err
+		}
+	}
+	if s.option.RequirePairs {
+		if err := validatePairs(ms); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.migrations = ms
+	s.files = byRaw
+	s.duplicates = duplicates
+	return nil
+}
+
+// byVersion returns the loaded migration of version in direction.
+func byVersion(ms *source.Migrations, version uint, direction source.Direction) *source.Migration {
+	if direction == source.Down {
+		m, _ := ms.Down(version)
+		return m
+	}
+	m, _ := ms.Up(version)
+	return m
+}
+
+// findFiles returns the migration files of every directory in Config.Paths.
+func (s *CodeUp) findFiles(ctx context.Context) ([]*file, error) {
+	if s.option.Config.SingleFile {
+		return s.readFile()
+	}
+	if s.option.Config.Manifest != "" {
+		return s.readManifest(ctx)
+	}
+	if s.option.Config.Archive {
+		return s.readArchive(ctx)
+	}
+
+	type owner struct{ ref, dir string }
+	var files []*file
+	owners := make(map[uint]owner)
+	for _, ref := range s.option.Config.refs() {
+		dirs := s.option.Config.Paths
+		if s.option.Config.PathGlob != "" {
+			matched, err := s.globDirs(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			dirs = appendNew(dirs, matched)
+		} else if len(dirs) == 0 {
+			dirs = []string{""}
+		}
+
+		listed, err := s.walkPaths(ctx, ref, dirs)
+		if err != nil {
+			return nil, err
+		}
+		for i, dir := range dirs {
+			found := listed[i]
+			for _, f := range found {
+				f.ref = ref
+				o, ok := owners[f.Version]
+				switch {
+				case ok && o.ref != ref:
+					return nil, fmt.Errorf("version %d found at both refs %q and %q", f.Version, o.ref, ref)
+				case ok && o.dir != dir:
+					return nil, fmt.Errorf("version %d found in both %q and %q", f.Version, o.dir, dir)
+				}
+				owners[f.Version] = owner{ref, dir}
+			}
+			files = append(files, found...)
+		}
+	}
+	return files, nil
+}
+
+// readFile returns Config.Path as the only migration file.
+func (s *CodeUp) readFile() ([]*file, error) {
+	name := path.Base(s.option.Config.listPath())
+	m, ok, err := s.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s is not a migration file", s.option.Config.Path)
+	}
+
+	m.Raw = name
+	return []*file{{Migration: m, ref: s.option.Config.listRef()}}, nil
+}
+
+// Reload lists the migration directory again and replaces the loaded
+// migrations with the ones found, at once for concurrent readers.
+// If listing fails, the loaded migrations are kept.
+//
+// Cached contents of files that are gone are evicted.
+func (s *CodeUp) Reload(ctx context.Context) error {
+	if _, err := s.loaded(); err != nil {
+		return err
+	}
+	if err := s.readDirectory(ctx); err != nil {
+		return err
+	}
+	if s.cache == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	keep := make(map[string]bool, len(s.files))
+	for raw := range s.files {
+		keep[s.fileCacheKey(raw)] = true
+	}
+	s.mu.RUnlock()
+	s.cache.retain(keep)
+
+	if s.option.Prefetch {
+		return s.prefetch(ctx)
+	}
+	return nil
+}
+
+// file is a migration file found in the repo tree.
+type file struct {
+	*source.Migration
+	id      string  // blob id of the tree entry, if known.
+	ref     string  // ref the file was listed at.
+	pinned  string  // commit the file is read at if Config.Lockfile pins another one.
+	content *string // content of a file unpacked from an archive.
+}
+
+// walk returns the migration files found in dir, which is relative to the listing path.
+func (s *CodeUp) walk(ctx context.Context, ref, dir string) ([]*file, error) {
+	entries, err := s.listTree(ctx, s.treeRequest(ref, path.Join(s.option.Config.listPath(), dir)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Subdirectories are walked concurrently, their files are
+	// merged in the order of the entries whatever order they finish in.
+	var wg sync.WaitGroup
+	found := make([][]*file, len(entries))
+	errs := make([]error, len(entries))
+	for i, v := range entries {
+		i, v := i, v
+		if tea.StringValue(v.Name) == "" {
+			s.log(Event{Op: OpSkip, Path: dir, Reason: "no name"})
+			continue
+		}
+		name := path.Join(dir, tea.StringValue(v.Name))
+		isDir := tea.StringValue(v.Type) == "tree"
+		switch {
+		case s.option.Config.VersionDirs && isDir:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				found[i], errs[i] = s.walkVersionDir(ctx, ref, name)
+			}()
+		case s.option.Config.Recursive && isDir:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				found[i], errs[i] = s.walk(ctx, ref, name)
+			}()
+		default:
+			var f *file
+			f, errs[i] = s.entryFile(name, v)
+			if f != nil {
+				found[i] = []*file{f}
+			}
+		}
+	}
+	wg.Wait()
+
+	var files []*file
+	for i := range entries {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		files = append(files, found[i]...)
+	}
+	return files, nil
+}
+
+// entryFile returns the migration file of the tree entry v named name,
+// or nil if the entry is skipped.
+func (s *CodeUp) entryFile(name string, v *devops.ListRepositoryTreeResponseBodyResult) (*file, error) {
+	if reason := skipReason(v); reason != "" {
+		s.log(Event{Op: OpSkip, Path: name, Reason: reason})
+		return nil, nil
+	}
+	if lockfile := s.option.Config.Lockfile; lockfile != "" && name == lockfile {
+		s.log(Event{Op: OpSkip, Path: name, Reason: "lockfile"})
+		return nil, nil
+	}
+
+	ok, err := matchFilter(s.option.Config.Filter, path.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.log(Event{Op: OpSkip, Path: name, Reason: "filtered"})
+		return nil, nil
+	}
+
+	m, ok, err := s.parse(path.Base(name))
+	if err != nil && s.option.LenientParse {
+		s.log(Event{Op: OpSkip, Path: name, Reason: "unparseable name", Err: err})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.log(Event{Op: OpSkip, Path: name, Reason: "unknown extension"})
+		return nil, nil
+	}
+	m.Raw = name
+	return &file{Migration: m, id: tea.StringValue(v.Id)}, nil
+}
+
+// walkVersionDir returns the up and down files of the version directory dir,
+// which is relative to the listing path.
+func (s *CodeUp) walkVersionDir(ctx context.Context, ref, dir string) ([]*file, error) {
+	version, identifier, ok := parseVersionDir(path.Base(dir))
+	if !ok {
+		s.log(Event{Op: OpSkip, Path: dir, Reason: "not a version directory"})
+		return nil, nil
+	}
+
+	entries, err := s.listTree(ctx, s.treeRequest(ref, path.Join(s.option.Config.listPath(), dir)))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*file
+	for _, v := range entries {
+		if tea.StringValue(v.Name) == "" {
+			s.log(Event{Op: OpSkip, Path: dir, Reason: "no name"})
+			continue
+		}
+		name := path.Join(dir, tea.StringValue(v.Name))
+		if reason := skipReason(v); reason != "" {
+			s.log(Event{Op: OpSkip, Path: name, Reason: reason})
+			continue
+		}
+
+		direction, ok := versionDirFile(path.Base(name))
+		if !ok {
+			s.log(Event{Op: OpSkip, Path: name, Reason: "unknown file"})
+			continue
+		}
+		m := &source.Migration{
+			Version:    version,
+			Identifier: identifier,
+			Direction:  direction,
+			Raw:        name,
+		}
+		files = append(files, &file{Migration: m, id: tea.StringValue(v.Id)})
+	}
+	return files, nil
+}
+
+// skipReason returns why the tree entry v is not a regular file,
+// or "" if it is one.
+func skipReason(v *devops.ListRepositoryTreeResponseBodyResult) string {
+	typ := tea.StringValue(v.Type)
+	mode := tea.StringValue(v.Mode)
+	switch {
+	case typ == "tree":
+		return "directory"
+	case typ == "commit" || mode == "160000":
+		return "submodule"
+	case mode == "120000":
+		return "symlink"
+	case typ != "" && typ != "blob":
+		return "not a file"
+	}
+	return ""
+}
+
+// matchFilter reports whether name passes filter.
+// filter is a glob pattern if it has any of "*?[", or a name prefix otherwise.
+func matchFilter(filter, name string) (bool, error) {
+	if strings.ContainsAny(filter, "*?[") {
+		return path.Match(filter, name)
+	}
+	return strings.HasPrefix(name, filter), nil
+}
+
+// listTree returns all entries of the tree listing request.
+func (s *CodeUp) listTree(ctx context.Context, request *devops.ListRepositoryTreeRequest) ([]*devops.ListRepositoryTreeResponseBodyResult, error) {
+	if s.listing != nil {
+		select {
+		case s.listing <- struct{}{}:
+			defer func() { <-s.listing }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ref, dir := tea.StringValue(request.RefName), tea.StringValue(request.Path)
+	ctx, end := s.trace(ctx, "ListRepositoryTree", dir, ref)
+	entries, err := s.listPages(ctx, request)
+	end(err)
+	if err != nil {
+		err = s.callContext(OpList, dir, ref, err)
+		if s.option.Metrics != nil {
+			s.option.Metrics.IncError(OpList)
+		}
+	}
+	s.log(Event{
+		Op:    OpList,
+		Path:  dir,
+		Ref:   ref,
+		Count: len(entries),
+		Err:   err,
+	})
+	return entries, err
+}
+
+// callContext returns err of the op call on filePath at ref as a *fs.PathError
+// naming the project and organization. The access token is redacted.
+func (s *CodeUp) callContext(op, filePath, ref string, err error) error {
+	c := s.option.Config
+	err = fmt.Errorf("ref %s, project %s, organization %s: %w", ref, c.ProjectId, c.OrganizationId, err)
+	var secrets []string
+	for _, secret := range []string{c.AccessToken, c.PersonalAccessToken} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) != 0 {
+		err = &redactedError{err: err, secrets: secrets}
+	}
+	return &fs.PathError{Op: op, Path: filePath, Err: err}
+}
+
+// headers returns the request headers of an API call.
+func (s *CodeUp) headers() map[string]*string {
+	pat := s.option.Config.PersonalAccessToken
+	if s.option.HeaderProvider == nil && pat == "" {
+		return s.option.Headers
+	}
+
+	h := make(map[string]*string, len(s.option.Headers)+1)
+	for k, v := range s.option.Headers {
+		h[k] = v
+	}
+	if s.option.HeaderProvider != nil {
+		for k, v := range s.option.HeaderProvider() {
+			h[k] = v
+		}
+	}
+	if pat != "" {
+		h[PersonalAccessTokenHeader] = tea.String(pat)
+	}
+	return h
+}
+
+// treeRequest returns the request listing the entries under dir at ref.
+func (s *CodeUp) treeRequest(ref, dir string) *devops.ListRepositoryTreeRequest {
+	return &devops.ListRepositoryTreeRequest{
+		OrganizationId: tea.String(s.option.Config.OrganizationId),
+		AccessToken:    tea.String(s.option.Config.AccessToken),
+		Path:           tea.String(dir),
+		RefName:        tea.String(ref),
+	}
+}
+
+// listOnce sends request without pagination.
+func (s *CodeUp) listOnce(ctx context.Context, request *devops.ListRepositoryTreeRequest) ([]*devops.ListRepositoryTreeResponseBodyResult, error) {
+	resp, err := callAPI(ctx, s, "ListRepositoryTree", func() (*devops.ListRepositoryTreeResponse, error) {
+		return s.client.ListRepositoryTreeWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			request,
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return nil, callError(err)
+	}
 	body := resp.Body
 	if !tea.BoolValue(body.Success) {
-		return errors.New(tea.StringValue(body.ErrorMessage))
+		return nil, responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+	}
+	return body.Result, nil
+}
+
+// listPages sends request page by page if the client supports it.
+func (s *CodeUp) listPages(ctx context.Context, request *devops.ListRepositoryTreeRequest) ([]*devops.ListRepositoryTreeResponseBodyResult, error) {
+	pager, ok := s.client.(treePager)
+	if !ok {
+		return s.listOnce(ctx, request)
 	}
 
-	for _, v := range body.Result {
-		m, err := source.Parse(tea.StringValue(v.Name))
+	pageSize := s.option.Config.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	var entries []*devops.ListRepositoryTreeResponseBodyResult
+	var last, token string
+	for page := 1; ; page++ {
+		resp, err := callAPI(ctx, s, "ListRepositoryTree", func() (*treePage, error) {
+			return pager.ListRepositoryTreePageWithOptions(
+				tea.String(s.option.Config.ProjectId),
+				request,
+				page,
+				pageSize,
+				token,
+				s.headers(),
+				s.option.Runtime,
+			)
+		})
 		if err != nil {
-			return err
+			return nil, callError(err)
+		}
+		body := resp.Body
+		if !tea.BoolValue(body.Success) {
+			return nil, responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+		}
+		if len(body.Result) == 0 {
+			return entries, nil
+		}
+
+		// A server that ignores the page parameters returns
+		// the same page again, stop instead of looping forever.
+		key := pageKey(body.Result)
+		if page > 1 && key == last {
+			return entries, nil
+		}
+		last = key
+
+		// A server paging by token returns one until the last page,
+		// which may be short or not; others are done on a short page.
+		entries = append(entries, body.Result...)
+		byToken := token != ""
+		token = resp.nextToken
+		if token == "" && (byToken || len(body.Result) < pageSize) {
+			return entries, nil
 		}
-		s.migrations.Append(m)
 	}
-	return nil
+}
+
+// pageKey returns a key telling apart pages of different entries.
+func pageKey(entries []*devops.ListRepositoryTreeResponseBodyResult) string {
+	var b strings.Builder
+	for _, e := range entries {
+		if e == nil {
+			b.WriteString("\x01")
+			continue
+		}
+		b.WriteString(tea.StringValue(e.Path))
+		b.WriteByte(0)
+		b.WriteString(tea.StringValue(e.Name))
+		b.WriteByte(0)
+		b.WriteString(tea.StringValue(e.Id))
+		b.WriteByte(0)
+	}
+	return b.String()
 }
 
 // read content of file.
 //
 // Because there is no way to get the http body of file content,
 // so read will return content directly (instead of return a body reader).
-func (s CodeUp) read(filePath string) (string, error) {
-	resp, err := s.client.GetFileBlobsWithOptions(
-		tea.String(s.option.Config.ProjectId),
-		&devops.GetFileBlobsRequest{
-			OrganizationId: tea.String(s.option.Config.OrganizationId),
-			AccessToken:    tea.String(s.option.Config.AccessToken),
-			FilePath:       tea.String(path.Join(s.option.Config.Path, filePath)),
-			Ref:            tea.String(s.option.Config.Ref),
-		},
-		s.option.Headers,
-		s.option.Runtime,
-	)
+func (s *CodeUp) read(ctx context.Context, filePath string) (string, error) {
+	if content, ok := s.unpacked(filePath); ok {
+		return content, nil
+	}
+	if s.cache == nil {
+		content, _, err := s.fetchShared(ctx, filePath)
+		return content, err
+	}
+
+	key := s.cacheKey(filePath)
+	var ref string
+	content, hit, err := s.cache.get(key, func() (string, error) {
+		content, at, err := s.fetchShared(ctx, filePath)
+		ref = at
+		return content, err
+	})
+	if hit && s.option.Metrics != nil {
+		s.option.Metrics.IncCacheHit()
+	}
+	if !hit && err == nil && strings.HasPrefix(key, blobKeyPrefix) && !s.readAtListed(filePath, ref) {
+		// The body is not the one of the blob id.
+		s.cache.forget(key)
+	}
+	return content, err
+}
+
+// unpacked returns the content of the file with the raw name
+// if it was unpacked from an archive.
+func (s *CodeUp) unpacked(raw string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if f, ok := s.files[raw]; ok && f.content != nil {
+		return *f.content, true
+	}
+	return "", false
+}
+
+// blobPath returns the repo path of the file with the raw name.
+func (s *CodeUp) blobPath(raw string) string {
+	p := path.Join(s.option.Config.blobBasePath(), raw)
+	if s.option.PathRewrite != nil {
+		p = s.option.PathRewrite(p)
+	}
+	return p
+}
+
+// blobKeyPrefix prefixes the cache keys of files keyed by blob id.
+const blobKeyPrefix = "blob:"
+
+// cacheKey returns the cache key of the file at filePath.
+//
+// Files are keyed by their blob id when the tree listing reported one,
+// so unchanged files share an entry whatever their path or ref.
+func (s *CodeUp) cacheKey(filePath string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fileCacheKey(filePath)
+}
+
+// fileCacheKey is cacheKey with s.mu held.
+func (s *CodeUp) fileCacheKey(filePath string) string {
+	if id := s.fileBlobId(filePath); id != "" {
+		return blobKeyPrefix + id
+	}
+	return s.fileReadRef(filePath) + ":" + s.blobPath(filePath)
+}
+
+// fileRef returns the ref the file with the raw name is read from,
+// the ref it was listed at unless Config.ReadRef replaces it.
+func (s *CodeUp) fileRef(raw string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fileReadRef(raw)
+}
+
+// fileReadRef is fileRef with s.mu held.
+func (s *CodeUp) fileReadRef(raw string) string {
+	ref := s.option.Config.listRef()
+	if f, ok := s.files[raw]; ok {
+		if f.pinned != "" {
+			return f.pinned
+		}
+		if f.ref != "" {
+			ref = f.ref
+		}
+	}
+	return s.option.Config.readRef(ref)
+}
+
+// blobId returns the blob id of the file with the raw name, or "" if it is not known.
+func (s *CodeUp) blobId(raw string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fileBlobId(raw)
+}
+
+// fileBlobId is blobId with s.mu held. The id listed is not known
+// to be the one read if the file is read from another ref.
+func (s *CodeUp) fileBlobId(raw string) string {
+	f, ok := s.files[raw]
+	if !ok || f.ref != "" && s.option.Config.readRef(f.ref) != f.ref {
+		return ""
+	}
+	return f.id
+}
+
+// fetch content of file from CodeUp, and the ref it was read at.
+//
+// The file is read at the ref it was listed at. If that ref is still not found
+// after Option.RefRetries retries, the file is read at Config.FallbackRef instead.
+func (s *CodeUp) fetch(ctx context.Context, filePath string) (string, string, error) {
+	return s.fetchAt(ctx, s.blobPath(filePath), s.fileRef(filePath))
+}
+
+// fetchAt reads the file at the repo path filePath with ref,
+// retried and falling back to Config.FallbackRef as configured.
+// It returns the content and the ref it was read at.
+func (s *CodeUp) fetchAt(ctx context.Context, filePath, ref string) (string, string, error) {
+	content, err := s.fetchRetry(ctx, filePath, ref)
+	if fallback := s.option.Config.FallbackRef; fallback != "" && errors.Is(err, ErrRefNotFound) {
+		ref = fallback
+		content, err = s.fetchRef(ctx, filePath, ref)
+	}
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if err := s.checkSize(filePath, content); err != nil {
+		return "", "", err
+	}
+	return content, ref, nil
+}
+
+// readAtListed reports whether a body of the file with the raw name
+// read at ref is the one of the blob id it was listed with.
+func (s *CodeUp) readAtListed(raw, ref string) bool {
+	return ref == s.fileRef(raw)
+}
+
+// checkSize returns ErrTooLarge if content of the file at filePath
+// is larger than Option.MaxSize.
+func (s *CodeUp) checkSize(filePath, content string) error {
+	if max := s.option.MaxSize; max > 0 && int64(len(content)) > max {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrTooLarge, filePath, len(content), max)
+	}
+	return nil
+}
+
+// fetchRef reads the file at filePath with ref.
+func (s *CodeUp) fetchRef(ctx context.Context, filePath, ref string) (string, error) {
+	ctx, end := s.trace(ctx, "GetFileBlobs", filePath, ref)
+	rctx, cancel := s.readContext(ctx)
+	content, err := s.getFileBlob(rctx, filePath, ref)
+	if err != nil && rctx.Err() == context.DeadlineExceeded && (ctx == nil || ctx.Err() == nil) {
+		err = fmt.Errorf("%w after %v", ErrTimeout, s.option.ReadTimeout)
+	}
+	cancel()
+	end(err)
+	if err != nil {
+		err = s.callContext(OpRead, filePath, ref, err)
+		if s.option.Metrics != nil {
+			s.option.Metrics.IncError(OpRead)
+		}
+	}
+	s.log(Event{
+		Op:   OpRead,
+		Path: filePath,
+		Ref:  ref,
+		Err:  err,
+	})
+	return content, err
+}
+
+// getFileBlob requests the content of the file at filePath with ref.
+func (s *CodeUp) getFileBlob(ctx context.Context, filePath, ref string) (string, error) {
+	resp, err := callAPI(ctx, s, "GetFileBlobs", func() (*devops.GetFileBlobsResponse, error) {
+		return s.client.GetFileBlobsWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			&devops.GetFileBlobsRequest{
+				OrganizationId: tea.String(s.option.Config.OrganizationId),
+				AccessToken:    tea.String(s.option.Config.AccessToken),
+				FilePath:       tea.String(filePath),
+				Ref:            tea.String(ref),
+			},
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return "", callError(err)
 	}
 	body := resp.Body
 	if !tea.BoolValue(body.Success) {
-		return "", errors.New(tea.StringValue(body.ErrorMessage))
+		return "", responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+	}
+	// An empty file may come back without a result.
+	if body.Result == nil {
+		return "", nil
 	}
 	return tea.StringValue(body.Result.Content), nil
 }
@@ -0,0 +1,102 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// commitFinder is implemented by clients that can look up
+// the last commit of a file.
+type commitFinder interface {
+	GetFileLastCommitWithOptions(repositoryId *string, request *devops.GetFileLastCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileLastCommitResponse, error)
+}
+
+// ReadUpWithMeta is like ReadUp but also returns the id of
+// the last commit that changed the file at its ref.
+//
+// GetFileBlobs does not report the commit of a file, so it is looked up
+// with one more API call, after the body is read. commitId is "" if the
+// client can not look it up. The commit is not the one of the body when:
+//   - the ref is a branch that moved between the two calls;
+//   - the body is served from CacheContents, Prefetch, Cache or CacheDir,
+//     read before the file changed at its ref;
+//   - one call found the ref and the other read Config.FallbackRef.
+//
+// Read at a commit id, or at a tag that does not move, without
+// Config.FallbackRef, to get the commit of the body.
+func (s *CodeUp) ReadUpWithMeta(version uint) (r io.ReadCloser, identifier, commitId string, err error) {
+	r, identifier, err = s.ReadUp(version)
+	if err != nil {
+		return nil, "", "", err
+	}
+	_, raw, _, _ := s.Describe(version)
+	return s.withCommit(r, identifier, raw)
+}
+
+// ReadDownWithMeta is like ReadDown but also returns the id of
+// the last commit that changed the file at its ref, see ReadUpWithMeta.
+func (s *CodeUp) ReadDownWithMeta(version uint) (r io.ReadCloser, identifier, commitId string, err error) {
+	r, identifier, err = s.ReadDown(version)
+	if err != nil {
+		return nil, "", "", err
+	}
+	_, _, raw, _ := s.Describe(version)
+	return s.withCommit(r, identifier, raw)
+}
+
+func (s *CodeUp) withCommit(r io.ReadCloser, identifier, raw string) (io.ReadCloser, string, string, error) {
+	commitId, err := s.lastCommit(s.ctx, raw)
+	if err != nil {
+		r.Close()
+		return nil, "", "", err
+	}
+	return r, identifier, commitId, nil
+}
+
+// lastCommit returns the id of the last commit that changed the file with the raw name.
+// If the ref of the file is not found, the commit at Config.FallbackRef is returned instead.
+func (s *CodeUp) lastCommit(ctx context.Context, raw string) (string, error) {
+	c, ok := s.client.(commitFinder)
+	if !ok {
+		return "", nil
+	}
+
+	filePath := s.blobPath(raw)
+	id, err := s.getFileLastCommit(ctx, c, filePath, s.fileRef(raw))
+	if ref := s.option.Config.FallbackRef; ref != "" && errors.Is(err, ErrRefNotFound) {
+		id, err = s.getFileLastCommit(ctx, c, filePath, ref)
+	}
+	return id, err
+}
+
+func (s *CodeUp) getFileLastCommit(ctx context.Context, c commitFinder, filePath, ref string) (string, error) {
+	resp, err := callAPI(ctx, s, "GetFileLastCommit", func() (*devops.GetFileLastCommitResponse, error) {
+		return c.GetFileLastCommitWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			&devops.GetFileLastCommitRequest{
+				OrganizationId: tea.String(s.option.Config.OrganizationId),
+				AccessToken:    tea.String(s.option.Config.AccessToken),
+				FilePath:       tea.String(filePath),
+				Sha:            tea.String(ref),
+			},
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return "", s.callContext(OpRead, filePath, ref, callError(err))
+	}
+	body := resp.Body
+	if !tea.BoolValue(body.Success) {
+		return "", s.callContext(OpRead, filePath, ref, responseError(body.ErrorCode, body.ErrorMessage, body.RequestId))
+	}
+	if body.Result == nil {
+		return "", nil
+	}
+	return tea.StringValue(body.Result.Id), nil
+}
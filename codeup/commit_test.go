@@ -0,0 +1,39 @@
+package codeup
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadWithMeta(t *testing.T) {
+	client := refFake{newFakeClient(sampleFiles)}
+	d := newTestDriver(t, client, testOption("db"))
+
+	r, id, commit, err := d.ReadUpWithMeta(2)
+	if got := readBody(t, r, err); got != "CREATE TABLE users;" || id != "users" {
+		t.Errorf("ReadUpWithMeta(2) = %q, %q", got, id)
+	}
+	if want := "commit-" + fakeBlobId("CREATE TABLE users;")[:7]; commit != want {
+		t.Errorf("commit = %q, want %q", commit, want)
+	}
+	r, _, commit, err = d.ReadDownWithMeta(1)
+	if readBody(t, r, err); commit != "commit-"+fakeBlobId("DROP TABLE a;")[:7] {
+		t.Errorf("ReadDownWithMeta(1) commit = %q", commit)
+	}
+	if calls := client.received("GetFileLastCommit"); len(calls) != 2 || calls[0].path != "db/2_users.up.sql" || calls[0].ref != "master" {
+		t.Errorf("commit lookups = %+v", calls)
+	}
+	if _, _, _, err := d.ReadDownWithMeta(3); !errors.Is(err, ErrNoDown) {
+		t.Errorf("ReadDownWithMeta(3): err = %v, want ErrNoDown", err)
+	}
+}
+
+// TestReadWithMetaNoFinder checks that a client that can not look up commits
+// reads with no commit id.
+func TestReadWithMetaNoFinder(t *testing.T) {
+	d := newTestDriver(t, newFakeClient(sampleFiles), testOption("db"))
+	r, _, commit, err := d.ReadUpWithMeta(1)
+	if got := readBody(t, r, err); got != "CREATE TABLE a;" || commit != "" {
+		t.Errorf("ReadUpWithMeta(1) = %q, commit %q", got, commit)
+	}
+}
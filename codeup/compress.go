@@ -0,0 +1,100 @@
+package codeup
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// acceptEncoding is sent with the GetFileBlobs calls of Option.Compress.
+const acceptEncoding = "gzip, deflate"
+
+// getCompressedBlob sends the GetFileBlobs call of params and query
+// asking for a compressed response, and decompresses it.
+//
+// The devops client reads failed responses itself, and can not read
+// compressed ones; such a call is sent again uncompressed.
+func (c pagedClient) getCompressedBlob(params *openapi.Params, headers, query map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error) {
+	result, err := c.callCompressed(params, headers, query, runtime)
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) {
+		return result, err
+	}
+
+	result = &devops.GetFileBlobsResponse{}
+	body, err := c.CallApi(params, &openapi.OpenApiRequest{Headers: headers, Query: query}, runtime)
+	if err != nil {
+		return result, err
+	}
+	err = tea.Convert(body, &result)
+	return result, err
+}
+
+func (c pagedClient) callCompressed(params *openapi.Params, headers, query map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error) {
+	// The header is sent as is: in canonical form, it keeps the HTTP
+	// transport from asking for gzip and decompressing on its own.
+	h := make(map[string]*string, len(headers)+1)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h["Accept-Encoding"] = tea.String(acceptEncoding)
+	binary := *params
+	binary.BodyType = tea.String("binary")
+
+	result := &devops.GetFileBlobsResponse{}
+	resp, err := c.CallApi(&binary, &openapi.OpenApiRequest{Headers: h, Query: query}, runtime)
+	if err != nil {
+		return result, err
+	}
+	body, ok := resp["body"].(io.ReadCloser)
+	if !ok {
+		return result, errors.New("GetFileBlobs returned no body")
+	}
+	defer body.Close()
+	respHeaders, _ := resp["headers"].(map[string]*string)
+
+	r, err := decodeBody(body, tea.StringValue(respHeaders["content-encoding"]))
+	if err != nil {
+		return result, err
+	}
+	obj, err := service.ReadAsJSON(r)
+	if err != nil {
+		return result, err
+	}
+	err = tea.Convert(map[string]interface{}{
+		"body":       obj,
+		"headers":    respHeaders,
+		"statusCode": resp["statusCode"],
+	}, &result)
+	return result, err
+}
+
+// decodeBody returns the decompressed content of r, sent with encoding
+// as its Content-Encoding.
+func decodeBody(r io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// Deflate should be zlib wrapped, but some servers send it raw.
+		br := bufio.NewReader(r)
+		if h, err := br.Peek(2); err == nil && (h[0]&0x0f) == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
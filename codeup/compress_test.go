@@ -0,0 +1,181 @@
+package codeup
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	iurl "net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newCompressingServer returns a server serving files as the devops API,
+// with the blobs it finds compressed with encoding and the others
+// failing with 404 compressed the same way, and the Accept-Encoding
+// headers of the blob calls it received.
+func newCompressingServer(t *testing.T, files map[string]string, encoding string) (*httptest.Server, func() []string) {
+	t.Helper()
+	fake := newFakeClient(files)
+	var mu sync.Mutex
+	var accepted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files/tree") {
+			entries, _ := fake.list(q.Get("refName"), q.Get("path"), false)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": entries})
+			return
+		}
+
+		accept := r.Header.Get("Accept-Encoding")
+		mu.Lock()
+		accepted = append(accepted, accept)
+		mu.Unlock()
+
+		status := http.StatusOK
+		var body interface{} = map[string]interface{}{
+			"success": true,
+			"result":  map[string]interface{}{"content": files[q.Get("filePath")]},
+		}
+		if _, ok := files[q.Get("filePath")]; !ok {
+			status = http.StatusNotFound
+			body = map[string]interface{}{"code": "FileNotFound", "message": "file not found", "requestId": "req-3"}
+		}
+		b, _ := json.Marshal(body)
+		if encoding != "" && strings.Contains(accept, encoding) {
+			b = compress(t, encoding, b)
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.WriteHeader(status)
+		w.Write(b)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), accepted...)
+	}
+}
+
+func compress(t *testing.T, encoding string, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCompress(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", ""} {
+		t.Run("encoding "+encoding, func(t *testing.T) {
+			server, accepted := newCompressingServer(t, sampleFiles, encoding)
+			option := testOption("db")
+			option.SkipRefCheck = true
+			option.Compress = true
+			d := newTestDriver(t, newServerClient(t, server.URL), option)
+
+			if got := readUp(t, d, 2); got != "CREATE TABLE users;" {
+				t.Errorf("ReadUp(2) = %q", got)
+			}
+			if got := accepted(); len(got) != 1 || got[0] != acceptEncoding {
+				t.Errorf("Accept-Encoding = %q, want %q", got, acceptEncoding)
+			}
+		})
+	}
+}
+
+// TestCompressError checks that a compressed failed response is read
+// by sending the call again uncompressed.
+func TestCompressError(t *testing.T) {
+	server, accepted := newCompressingServer(t, sampleFiles, "gzip")
+	option := testOption("db")
+	option.SkipRefCheck = true
+	option.Compress = true
+	d := newTestDriver(t, newServerClient(t, server.URL), option)
+
+	_, err := d.fetchRef(nil, "db/9_missing.up.sql", "master")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("err = %v, want ErrFileNotFound", err)
+	}
+	if got := accepted(); len(got) != 2 || got[0] != acceptEncoding || got[1] == acceptEncoding {
+		t.Errorf("Accept-Encoding = %q, want a compressed call then an uncompressed one", got)
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	raw := new(bytes.Buffer)
+	fw, _ := flate.NewWriter(raw, flate.DefaultCompression)
+	fw.Write([]byte("content"))
+	fw.Close()
+
+	tests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"", []byte("content")},
+		{"identity", []byte("content")},
+		{"gzip", compress(t, "gzip", []byte("content"))},
+		{"deflate", compress(t, "deflate", []byte("content"))},
+		{"deflate", raw.Bytes()},
+	}
+	for _, tt := range tests {
+		r, err := decodeBody(bytes.NewReader(tt.body), tt.encoding)
+		if err != nil {
+			t.Errorf("decodeBody(%q): %v", tt.encoding, err)
+			continue
+		}
+		if got, err := io.ReadAll(r); string(got) != "content" || err != nil {
+			t.Errorf("decodeBody(%q) = %q, %v", tt.encoding, got, err)
+		}
+	}
+	if _, err := decodeBody(strings.NewReader(""), "br"); err == nil {
+		t.Error("decodeBody of br succeeded")
+	}
+}
+
+func TestCompressNeedsDevopsClient(t *testing.T) {
+	option := testOption("db")
+	option.Compress = true
+	if _, err := WithInstance(newFakeClient(sampleFiles), option); err == nil {
+		t.Error("Compress with a fake client succeeded")
+	}
+}
+
+func TestCompressUrl(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"&compress=true", true, false},
+		{"&compress=maybe", false, true},
+	}
+	for _, tt := range tests {
+		u, _ := iurl.Parse("codeup://org/project/db?ref=master" + tt.query)
+		option, err := optionFromUrl(u)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v", tt.query, err)
+			continue
+		}
+		if option.Compress != tt.want {
+			t.Errorf("%q: Compress = %v, want %v", tt.query, option.Compress, tt.want)
+		}
+	}
+}
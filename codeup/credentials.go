@@ -0,0 +1,182 @@
+package codeup
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	credential "github.com/aliyun/credentials-go/credentials"
+)
+
+// Credentials is a set of Alibaba Cloud access credentials.
+type Credentials struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string // optional, set for STS credentials.
+}
+
+// CredentialProvider returns the credentials used by the devops client.
+//
+// It is called again whenever a call fails because the security token expired.
+type CredentialProvider func() (Credentials, error)
+
+func newCredential(c Credentials) (credential.Credential, error) {
+	typ := "access_key"
+	if c.SecurityToken != "" {
+		typ = "sts"
+	}
+	return credential.NewCredential(&credential.Config{
+		Type:            tea.String(typ),
+		AccessKeyId:     tea.String(c.AccessKeyId),
+		AccessKeySecret: tea.String(c.AccessKeySecret),
+		SecurityToken:   tea.String(c.SecurityToken),
+	})
+}
+
+// DefaultRoleSessionName is the session name used to assume a RAM role
+// when the URL names none.
+const DefaultRoleSessionName = "golang-migrate"
+
+// roleCredential returns the credential assuming the RAM role arn.
+// The role is assumed with the access key if one is given,
+// or else with the OIDC token of the pod (RRSA), which is found with
+// the ALIBABA_CLOUD_OIDC_PROVIDER_ARN and ALIBABA_CLOUD_OIDC_TOKEN_FILE variables.
+func roleCredential(key, secret, arn, session string) (credential.Credential, error) {
+	if session == "" {
+		session = DefaultRoleSessionName
+	}
+	if key != "" && secret != "" {
+		return credential.NewCredential(&credential.Config{
+			Type:            tea.String("ram_role_arn"),
+			AccessKeyId:     tea.String(key),
+			AccessKeySecret: tea.String(secret),
+			RoleArn:         tea.String(arn),
+			RoleSessionName: tea.String(session),
+		})
+	}
+
+	provider := os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN")
+	tokenFile := os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE")
+	if provider == "" || tokenFile == "" {
+		return nil, errors.New("roleArn needs an access key or ALIBABA_CLOUD_OIDC_PROVIDER_ARN and ALIBABA_CLOUD_OIDC_TOKEN_FILE")
+	}
+	return credential.NewCredential(&credential.Config{
+		Type:              tea.String("oidc_role_arn"),
+		OIDCProviderArn:   tea.String(provider),
+		OIDCTokenFilePath: tea.String(tokenFile),
+		RoleArn:           tea.String(arn),
+		RoleSessionName:   tea.String(session),
+	})
+}
+
+// isTokenExpired reports whether err is caused by an expired security token.
+func isTokenExpired(err error) bool {
+	var e *tea.SDKError
+	if !errors.As(err, &e) {
+		return false
+	}
+	code := tea.StringValue(e.Code)
+	return strings.Contains(code, "SecurityToken") && strings.Contains(code, "Expired")
+}
+
+// refreshingClient renews the credential of a devops client
+// with a CredentialProvider once its security token expires.
+type refreshingClient struct {
+	mu       sync.RWMutex
+	client   *devops.Client
+	provider CredentialProvider
+	prefix   string // see pagedClient.
+	compress bool   // see pagedClient.
+}
+
+func (c *refreshingClient) current() *devops.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// renew replaces stale with a copy carrying fresh credentials.
+// If another call renewed it already, the current client is returned.
+func (c *refreshingClient) renew(stale *devops.Client) (*devops.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != stale {
+		return c.client, nil
+	}
+
+	creds, err := c.provider()
+	if err != nil {
+		return nil, err
+	}
+	cred, err := newCredential(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	client := *stale
+	client.Credential = cred
+	c.client = &client
+	return c.client, nil
+}
+
+// call runs f and runs it once more with renewed credentials
+// if it failed because the security token expired.
+func call[T any](c *refreshingClient, f func(pagedClient) (T, error)) (T, error) {
+	client := c.current()
+	v, err := f(pagedClient{Client: client, prefix: c.prefix, compress: c.compress})
+	if !isTokenExpired(err) {
+		return v, err
+	}
+
+	client, err = c.renew(client)
+	if err != nil {
+		return v, err
+	}
+	return f(pagedClient{Client: client, prefix: c.prefix, compress: c.compress})
+}
+
+func (c *refreshingClient) ListRepositoryTreeWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.ListRepositoryTreeResponse, error) {
+	return call(c, func(client pagedClient) (*devops.ListRepositoryTreeResponse, error) {
+		return client.ListRepositoryTreeWithOptions(repositoryId, request, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) ListRepositoryTreePageWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, page, pageSize int, token string, headers map[string]*string, runtime *service.RuntimeOptions) (*treePage, error) {
+	return call(c, func(client pagedClient) (*treePage, error) {
+		return client.ListRepositoryTreePageWithOptions(repositoryId, request, page, pageSize, token, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) GetFileBlobsWithOptions(repositoryId *string, request *devops.GetFileBlobsRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error) {
+	return call(c, func(client pagedClient) (*devops.GetFileBlobsResponse, error) {
+		return client.GetFileBlobsWithOptions(repositoryId, request, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) GetBranchInfoWithOptions(repositoryId *string, request *devops.GetBranchInfoRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetBranchInfoResponse, error) {
+	return call(c, func(client pagedClient) (*devops.GetBranchInfoResponse, error) {
+		return client.GetBranchInfoWithOptions(repositoryId, request, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) GetRepositoryTagWithOptions(repositoryId *string, request *devops.GetRepositoryTagRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryTagResponse, error) {
+	return call(c, func(client pagedClient) (*devops.GetRepositoryTagResponse, error) {
+		return client.GetRepositoryTagWithOptions(repositoryId, request, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) GetRepositoryCommitWithOptions(repositoryId *string, sha *string, request *devops.GetRepositoryCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryCommitResponse, error) {
+	return call(c, func(client pagedClient) (*devops.GetRepositoryCommitResponse, error) {
+		return client.GetRepositoryCommitWithOptions(repositoryId, sha, request, headers, runtime)
+	})
+}
+
+func (c *refreshingClient) GetFileLastCommitWithOptions(repositoryId *string, request *devops.GetFileLastCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileLastCommitResponse, error) {
+	return call(c, func(client pagedClient) (*devops.GetFileLastCommitResponse, error) {
+		return client.GetFileLastCommitWithOptions(repositoryId, request, headers, runtime)
+	})
+}
@@ -0,0 +1,107 @@
+package codeup
+
+import (
+	"errors"
+	"testing"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestNewCredential(t *testing.T) {
+	tests := []struct {
+		creds Credentials
+		want  string
+	}{
+		{Credentials{AccessKeyId: "id", AccessKeySecret: "secret"}, "access_key"},
+		{Credentials{AccessKeyId: "id", AccessKeySecret: "secret", SecurityToken: "sts"}, "sts"},
+	}
+	for _, tt := range tests {
+		cred, err := newCredential(tt.creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tea.StringValue(cred.GetType()); got != tt.want {
+			t.Errorf("type = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestRoleCredential(t *testing.T) {
+	t.Setenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN", "")
+	t.Setenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE", "")
+	if _, err := roleCredential("", "", "acs:ram::1:role/r", ""); err == nil {
+		t.Error("role without an access key or OIDC token succeeded")
+	}
+	cred, err := roleCredential("id", "secret", "acs:ram::1:role/r", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tea.StringValue(cred.GetType()); got != "ram_role_arn" {
+		t.Errorf("type = %s, want ram_role_arn", got)
+	}
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("InvalidSecurityToken.Expired"), false},
+		{sdkError("InvalidSecurityToken.Expired", 400, "expired"), true},
+		{sdkError("InvalidSecurityToken.Malformed", 400, "bad"), false},
+	}
+	for _, tt := range tests {
+		if got := isTokenExpired(tt.err); got != tt.want {
+			t.Errorf("isTokenExpired(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshingClient(t *testing.T) {
+	renewed := 0
+	c := &refreshingClient{
+		client: &devops.Client{},
+		provider: func() (Credentials, error) {
+			renewed++
+			return Credentials{AccessKeyId: "id", AccessKeySecret: "secret", SecurityToken: "new"}, nil
+		},
+		prefix: "/gw",
+	}
+	stale := c.current()
+
+	var clients []*devops.Client
+	v, err := call(c, func(client pagedClient) (int, error) {
+		if client.prefix != "/gw" {
+			t.Errorf("prefix = %q, want /gw", client.prefix)
+		}
+		clients = append(clients, client.Client)
+		if len(clients) == 1 {
+			return 0, sdkError("InvalidSecurityToken.Expired", 400, "expired")
+		}
+		return 1, nil
+	})
+	if v != 1 || err != nil {
+		t.Fatalf("call = %v, %v; want the retried result", v, err)
+	}
+	if renewed != 1 || len(clients) != 2 || clients[0] != stale || clients[1] == stale {
+		t.Errorf("renewed %d times, clients %v; want one retry with a new client", renewed, clients)
+	}
+	if clients[1].Credential == nil {
+		t.Error("renewed client has no credential")
+	}
+
+	// A client renewed by another call is not renewed again.
+	if got, err := c.renew(stale); err != nil || got != clients[1] || renewed != 1 {
+		t.Errorf("renew(stale) = %v, %v after %d renewals; want the current client", got, err, renewed)
+	}
+
+	fail := errors.New("no credentials")
+	c.provider = func() (Credentials, error) { return Credentials{}, fail }
+	if _, err := call(c, func(pagedClient) (int, error) {
+		return 0, sdkError("InvalidSecurityToken.Expired", 400, "expired")
+	}); err != fail {
+		t.Errorf("call with a failing provider = %v, want %v", err, fail)
+	}
+}
@@ -0,0 +1,198 @@
+package codeup
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// diffContext is the number of unchanged lines around each hunk of a diff.
+const diffContext = 3
+
+// Diff returns the unified diff of the body of the migration of version
+// in direction from refA to refB, or nothing if the bodies are the same.
+// Both bodies are read from CodeUp, without the cache or Option.Transform,
+// and exactly at the refs given: Config.FallbackRef is not used.
+func (s *CodeUp) Diff(version uint, direction source.Direction, refA, refB string) ([]byte, error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil, err
+	}
+	m := byVersion(ms, version, direction)
+	if m == nil {
+		return nil, &fs.PathError{
+			Op:   "diff " + string(direction) + " version " + strconv.FormatUint(uint64(version), 10),
+			Path: s.option.Config.Path,
+			Err:  fs.ErrNotExist,
+		}
+	}
+
+	a, err := s.bodyAt(m, refA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.bodyAt(m, refB)
+	if err != nil {
+		return nil, err
+	}
+	return unifiedDiff(refA+":"+m.Raw, refB+":"+m.Raw, a, b), nil
+}
+
+// bodyAt returns the content of m at ref, decompressed if it is gzip compressed.
+// It is read at ref only, without Config.FallbackRef, so it fails with
+// ErrRefNotFound if ref does not exist.
+func (s *CodeUp) bodyAt(m *source.Migration, ref string) (string, error) {
+	filePath := s.blobPath(m.Raw)
+	content, err := s.fetchRetry(s.withVersion(s.ctx, m.Version), filePath, ref)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkSize(filePath, content); err != nil {
+		return "", err
+	}
+	if isGzip(m.Raw, content) {
+		return s.gunzip(m.Raw, content)
+	}
+	return content, nil
+}
+
+// diffOp is a line of a diff: kept (' '), deleted ('-') or inserted ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff from a, named nameA, to b, named nameB.
+func unifiedDiff(nameA, nameB, a, b string) []byte {
+	if a == b {
+		return nil
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// posA[i] and posB[i] are the lines of a and b before ops[i].
+	posA := make([]int, len(ops)+1)
+	posB := make([]int, len(ops)+1)
+	for i, op := range ops {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if op.kind != '+' {
+			posA[i+1]++
+		}
+		if op.kind != '-' {
+			posB[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+	last := 0
+	for i := 0; ; {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// A hunk ends after a run of unchanged lines too long
+		// to join it with the next change, or at the end.
+		start := i - diffContext
+		if start < last {
+			start = last
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > run {
+					end = run
+				}
+				break
+			}
+			end = run
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n",
+			hunkRange(posA[start], posA[end]-posA[start]),
+			hunkRange(posB[start], posB[end]-posB[start]))
+		for _, op := range ops[start:end] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		last, i = end, end
+	}
+	return buf.Bytes()
+}
+
+// hunkRange formats the range of n lines after line pos of a hunk header.
+func hunkRange(pos, n int) string {
+	if n == 0 {
+		return strconv.Itoa(pos) + ",0"
+	}
+	if n == 1 {
+		return strconv.Itoa(pos + 1)
+	}
+	return strconv.Itoa(pos+1) + "," + strconv.Itoa(n)
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit from a to b by their longest common
+// subsequence. Migrations are small enough for its quadratic cost.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
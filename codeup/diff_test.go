@@ -0,0 +1,78 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{
+			"change",
+			"a\nb\nc\n", "a\nB\nc\n",
+			"--- x\n+++ y\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"insert into empty",
+			"", "a\n",
+			"--- x\n+++ y\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"no newline at end",
+			"a\n", "a",
+			"--- x\n+++ y\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n2\n3\n4\n5\n6\n7\n8\n9\n11\n",
+			"--- x\n+++ y\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+11\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(unifiedDiff("x", "y", tt.a, tt.b)); got != tt.want {
+				t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	client.set("feature", "db/1_init.up.sql", "CREATE TABLE b;")
+	d := newTestDriver(t, client, testOption("db"))
+
+	got, err := d.Diff(1, source.Up, "master", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- master:1_init.up.sql\n+++ feature:1_init.up.sql\n@@ -1 +1 @@\n" +
+		"-CREATE TABLE a;\n\\ No newline at end of file\n+CREATE TABLE b;\n\\ No newline at end of file\n"
+	if string(got) != want {
+		t.Errorf("Diff =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := d.Diff(3, source.Down, "master", "feature"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Diff of a missing migration: err = %v", err)
+	}
+}
+
+// TestDiffNoFallback checks that Diff reads exactly the refs given.
+func TestDiffNoFallback(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	option := testOption("db")
+	option.Config.FallbackRef = "master"
+	d := newTestDriver(t, client, option)
+
+	if _, err := d.Diff(1, source.Up, "master", "gone"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Diff to a missing ref: err = %v, want ErrRefNotFound", err)
+	}
+}
@@ -0,0 +1,78 @@
+package codeup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// diskCache stores file contents in a directory, one file per blob id.
+//
+// Blob ids are content hashes, so an entry never goes stale:
+// a changed file has a new blob id and is downloaded again.
+type diskCache struct {
+	dir string
+}
+
+func (c diskCache) path(id string) string {
+	return filepath.Join(c.dir, "blob-"+id)
+}
+
+// load returns the content stored for id, if any.
+func (c diskCache) load(id string) (string, bool) {
+	b, err := os.ReadFile(c.path(id))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// store saves content for id. The entry is written to a temporary file
+// first, so concurrent runs never load a partly written entry.
+func (c diskCache) store(id, content string) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(id))
+}
+
+// fetchDisk is fetch with the disk cache of Option.CacheDir in front of it.
+// Only files whose blob id was reported by the tree listing are cached,
+// and only if they were read at the ref they were listed at.
+func (s *CodeUp) fetchDisk(ctx context.Context, raw string) (string, string, error) {
+	id := s.blobId(raw)
+	if s.option.CacheDir == "" || id == "" {
+		return s.fetch(ctx, raw)
+	}
+
+	c := diskCache{dir: s.option.CacheDir}
+	if content, ok := c.load(id); ok {
+		if s.option.Metrics != nil {
+			s.option.Metrics.IncCacheHit()
+		}
+		return content, s.fileRef(raw), nil
+	}
+
+	content, ref, err := s.fetch(ctx, raw)
+	if err != nil {
+		return "", "", err
+	}
+	if s.readAtListed(raw, ref) {
+		// The cache is best effort, a failed store only costs a download next time.
+		_ = c.store(id, content)
+	}
+	return content, ref, nil
+}
@@ -0,0 +1,106 @@
+package codeup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	client := newFakeClient(sampleFiles)
+	for i := 0; i < 2; i++ {
+		option := testOption("db")
+		option.CacheDir = dir
+		d := newTestDriver(t, client, option)
+		if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+			t.Errorf("run %d: ReadUp(1) = %q", i, got)
+		}
+	}
+	if n := client.count("GetFileBlobs"); n != 1 {
+		t.Errorf("GetFileBlobs called %d times, want 1", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "blob-"+fakeBlobId("CREATE TABLE a;") {
+		t.Errorf("cache entries = %v, want one for the blob id", entries)
+	}
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	c := diskCache{dir: t.TempDir()}
+	if _, ok := c.load("a"); ok {
+		t.Error("load of an empty cache found an entry")
+	}
+	if err := c.store("a", "content"); err != nil {
+		t.Fatal(err)
+	}
+	if content, ok := c.load("a"); !ok || content != "content" {
+		t.Errorf("load = %q, %v", content, ok)
+	}
+
+	blocked := diskCache{dir: filepath.Join(c.path("a"), "sub")}
+	if err := blocked.store("b", "content"); err == nil {
+		t.Error("store under a file succeeded")
+	}
+}
+
+// TestDiskCacheNoBlobId checks that files listed without a blob id are not cached.
+func TestDiskCacheNoBlobId(t *testing.T) {
+	client := newFakeClient(nil)
+	client.entries = func(ref, dir string) []*devops.ListRepositoryTreeResponseBodyResult {
+		return []*devops.ListRepositoryTreeResponseBodyResult{{Name: tea.String("1_a.up.sql"), Type: tea.String("blob")}}
+	}
+	client.set("master", "db/1_a.up.sql", "SELECT 1;")
+	dir := t.TempDir()
+	option := testOption("db")
+	option.CacheDir = dir
+	d := newTestDriver(t, client, option)
+	readUp(t, d, 1)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cache entries = %v, want none", entries)
+	}
+}
+
+// TestDiskCacheFallbackRef checks that a body read at Config.FallbackRef
+// is not cached under the blob id listed at the primary ref.
+func TestDiskCacheFallbackRef(t *testing.T) {
+	client := newFakeClient(map[string]string{"db/1_a.up.sql": "SELECT 0;"})
+	client.set("stable", "db/1_a.up.sql", "SELECT 1;")
+	missing := true
+	client.blobErr = func(ref, filePath string) error {
+		if ref == "master" && missing {
+			return sdkError("NotFound", 404, "branch master not found")
+		}
+		return nil
+	}
+	dir := t.TempDir()
+	option := testOption("db")
+	option.Config.FallbackRef = "stable"
+	option.CacheDir = dir
+	option.CacheContents = true
+	option.Cache = NewCache()
+	d := newTestDriver(t, client, option)
+
+	if got := readUp(t, d, 1); got != "SELECT 1;" {
+		t.Fatalf("ReadUp(1) = %q, want the body at stable", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cache entries = %v, want none", entries)
+	}
+
+	missing = false
+	if got := readUp(t, d, 1); got != "SELECT 0;" {
+		t.Errorf("ReadUp(1) = %q after master is back, want the body at master", got)
+	}
+	d2 := newTestDriver(t, client, option)
+	if got := readUp(t, d2, 1); got != "SELECT 0;" {
+		t.Errorf("ReadUp(1) of another driver = %q, want the body at master", got)
+	}
+}
@@ -0,0 +1,36 @@
+package codeup
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	regionPattern  = regexp.MustCompile(`^[a-z]{2}(-[a-z0-9]+)+$`)
+	networkPattern = regexp.MustCompile(`^[a-z]+$`)
+)
+
+// RegionEndpoint returns the devops endpoint of region, e.g.
+// "devops.cn-hangzhou.aliyuncs.com" for "cn-hangzhou".
+func RegionEndpoint(region string) (string, error) {
+	if !regionPattern.MatchString(region) {
+		return "", fmt.Errorf("invalid region %q", region)
+	}
+	return "devops." + region + ".aliyuncs.com", nil
+}
+
+// NetworkEndpoint returns the devops endpoint of region in network, e.g.
+// "devops-vpc.cn-hangzhou.aliyuncs.com" for "cn-hangzhou" and "vpc".
+// It is RegionEndpoint if network is "" or "public".
+func NetworkEndpoint(region, network string) (string, error) {
+	if network == "" || network == "public" {
+		return RegionEndpoint(region)
+	}
+	if !regionPattern.MatchString(region) {
+		return "", fmt.Errorf("invalid region %q", region)
+	}
+	if !networkPattern.MatchString(network) {
+		return "", fmt.Errorf("invalid network %q", network)
+	}
+	return "devops-" + network + "." + region + ".aliyuncs.com", nil
+}
@@ -0,0 +1,78 @@
+package codeup
+
+import (
+	"errors"
+	iurl "net/url"
+	"os"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// DefaultEndpoint is the devops endpoint used by FromEnv
+// when neither CODEUP_ENDPOINT nor CODEUP_REGION is set.
+const DefaultEndpoint = "devops.cn-hangzhou.aliyuncs.com"
+
+// FromEnv returns a new driver instance configured by environment variables:
+//
+//	CODEUP_PROJECT_ID    Config.ProjectId, required.
+//	CODEUP_ORG_ID        Config.OrganizationId, required.
+//	CODEUP_ACCESS_TOKEN  Config.AccessToken.
+//	CODEUP_PATH          Config.Path.
+//	CODEUP_REF           Config.Ref, default is DefaultRef.
+//	CODEUP_ENDPOINT      devops endpoint, default is DefaultEndpoint.
+//	CODEUP_REGION        region of the devops endpoint, instead of CODEUP_ENDPOINT.
+//
+// The client credentials are read from the ALIBABA_CLOUD_* variables
+// and the HTTPS proxy from the standard proxy variables, as in Open.
+func FromEnv() (source.Driver, error) {
+	config, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &iurl.URL{Host: os.Getenv("CODEUP_ENDPOINT")}
+	if u.Host == "" {
+		u.Host = DefaultEndpoint
+	}
+	if region := os.Getenv("CODEUP_REGION"); region != "" {
+		u.RawQuery = iurl.Values{"region": {region}}.Encode()
+	}
+	clientConfig, err := clientConfigFromUrl(u)
+	if err != nil {
+		return nil, err
+	}
+	client, err := devops.NewClient(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	option := NewOption(config)
+	option.Transport = &Transport{}
+	option.Transport.proxyFromEnvironment(tea.StringValue(clientConfig.Endpoint))
+	return WithInstance(client, option)
+}
+
+func configFromEnv() (Config, error) {
+	c := Config{
+		ProjectId:      os.Getenv("CODEUP_PROJECT_ID"),
+		OrganizationId: os.Getenv("CODEUP_ORG_ID"),
+		AccessToken:    os.Getenv("CODEUP_ACCESS_TOKEN"),
+		Path:           cleanPath(os.Getenv("CODEUP_PATH")),
+		Ref:            os.Getenv("CODEUP_REF"),
+	}
+	if c.ProjectId == "" {
+		return Config{}, errors.New("missing CODEUP_PROJECT_ID")
+	}
+	if c.OrganizationId == "" {
+		return Config{}, errors.New("missing CODEUP_ORG_ID")
+	}
+	if c.Ref == "" {
+		c.Ref = DefaultRef
+	}
+	if c.Ref == "" {
+		return Config{}, errors.New("missing CODEUP_REF and DefaultRef is empty")
+	}
+	return c, nil
+}
@@ -0,0 +1,83 @@
+package codeup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "ALL_PROXY", "NO_PROXY"} {
+		t.Setenv(name, "")
+		t.Setenv(strings.ToLower(name), "")
+	}
+	for name, v := range env {
+		t.Setenv(name, v)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantRef string
+		wantErr bool
+	}{
+		{"default ref", map[string]string{"CODEUP_PROJECT_ID": "p", "CODEUP_ORG_ID": "o"}, DefaultRef, false},
+		{"ref", map[string]string{"CODEUP_PROJECT_ID": "p", "CODEUP_ORG_ID": "o", "CODEUP_REF": "dev"}, "dev", false},
+		{"no project", map[string]string{"CODEUP_ORG_ID": "o"}, "", true},
+		{"no org", map[string]string{"CODEUP_PROJECT_ID": "p"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CODEUP_PROJECT_ID", "CODEUP_ORG_ID", "CODEUP_REF", "CODEUP_PATH"} {
+				t.Setenv(name, "")
+			}
+			setEnv(t, tt.env)
+			c, err := configFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			if c.Ref != tt.wantRef {
+				t.Errorf("Ref = %q, want %q", c.Ref, tt.wantRef)
+			}
+		})
+	}
+}
+
+// TestFromEnvProxy checks that FromEnv takes the proxy from ALL_PROXY,
+// which the devops client ignores, like Open.
+func TestFromEnvProxy(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		http.Error(w, "no", http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	setEnv(t, map[string]string{
+		"ALL_PROXY":                       proxy.URL,
+		"CODEUP_PROJECT_ID":               "p",
+		"CODEUP_ORG_ID":                   "o",
+		"CODEUP_ACCESS_TOKEN":             "token",
+		"CODEUP_ENDPOINT":                 "codeup.invalid",
+		"CODEUP_REGION":                   "",
+		"ALIBABA_CLOUD_ACCESS_KEY_ID":     "id",
+		"ALIBABA_CLOUD_ACCESS_KEY_SECRET": "secret",
+	})
+	if _, err := FromEnv(); err == nil {
+		t.Fatal("FromEnv succeeded through a failing proxy")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hosts) == 0 || hosts[0] != "codeup.invalid:443" {
+		t.Errorf("proxy received %v, want a request for codeup.invalid:443", hosts)
+	}
+}
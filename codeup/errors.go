@@ -0,0 +1,222 @@
+package codeup
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+var (
+	// ErrFileNotFound is returned when CodeUp reports that a file does not exist.
+	// It matches fs.ErrNotExist.
+	ErrFileNotFound error = notExistError("file not found")
+
+	// ErrNoDown is returned by ReadDown for a version that has only an up migration.
+	// It matches fs.ErrNotExist, which migrate treats as an empty migration.
+	ErrNoDown error = notExistError("no down migration")
+
+	// ErrNoMigrations is returned by First when the listed directory has no migrations.
+	// It matches fs.ErrNotExist, which migrate treats as no change.
+	ErrNoMigrations error = notExistError("no migrations found")
+
+	// ErrAPIFailure is returned when a CodeUp API call is rejected.
+	ErrAPIFailure = errors.New("codeup api failure")
+
+	// ErrUnauthenticated is returned when CodeUp rejects the credentials.
+	// Calls failing with it are never retried.
+	ErrUnauthenticated = errors.New("codeup authentication failed")
+
+	// ErrForbidden is returned when the credentials have no permission
+	// on the organization or project. Calls failing with it are never retried.
+	ErrForbidden = errors.New("codeup permission denied")
+
+	// ErrRefNotFound is returned when Config.Ref names no branch, tag or commit.
+	ErrRefNotFound = errors.New("ref not found")
+
+	// ErrTimeout is returned when a read takes longer than Option.ReadTimeout.
+	// Unlike ErrFileNotFound, a read that timed out may succeed when retried.
+	ErrTimeout = errors.New("read timed out")
+
+	// ErrTooLarge is returned when a file is larger than Option.MaxSize.
+	ErrTooLarge = errors.New("file too large")
+
+	// ErrTooManyMigrations is returned when loading finds more migration files
+	// than Option.MaxMigrations.
+	ErrTooManyMigrations = errors.New("too many migrations")
+
+	// ErrChecksumMismatch is returned when a body does not match Option.Checksums.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrClosed is returned when the driver is used after Close.
+	ErrClosed = errors.New("driver is closed")
+)
+
+// notExistError is an error that matches fs.ErrNotExist.
+type notExistError string
+
+func (e notExistError) Error() string { return string(e) }
+
+func (e notExistError) Is(target error) bool { return target == fs.ErrNotExist }
+
+// APIError is an error reported by the CodeUp API.
+// It matches ErrAPIFailure, ErrUnauthenticated or ErrForbidden
+// if the call was denied, and ErrRefNotFound or ErrFileNotFound
+// if the ref or the resource was not found.
+type APIError struct {
+	Code      string // error code of the API, or the HTTP status code if it sent none.
+	Message   string
+	RequestId string // id of the failed request, if reported.
+
+	cause error // underlying SDK error, if any.
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+func (e *APIError) Unwrap() error { return e.cause }
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAPIFailure:
+		return true
+	case ErrUnauthenticated:
+		return e.unauthenticated()
+	case ErrForbidden:
+		return e.forbidden()
+	case ErrRefNotFound:
+		return e.notFound() && e.refNotFound()
+	case ErrFileNotFound, fs.ErrNotExist:
+		return e.notFound() && !e.refNotFound()
+	}
+	return false
+}
+
+func (e *APIError) unauthenticated() bool {
+	code := strings.ToLower(e.Code)
+	return code == "401" ||
+		strings.Contains(code, "unauthorized") ||
+		strings.Contains(code, "unauthenticated") ||
+		strings.Contains(code, "invalidaccesskeyid") ||
+		strings.Contains(code, "signaturedoesnotmatch") ||
+		strings.Contains(code, "invalidtoken")
+}
+
+func (e *APIError) forbidden() bool {
+	code := strings.ToLower(e.Code)
+	return code == "403" ||
+		strings.Contains(code, "forbidden") ||
+		strings.Contains(code, "nopermission") ||
+		strings.Contains(code, "permissiondenied") ||
+		strings.Contains(code, "accessdenied")
+}
+
+// notFound reports whether the resource was not found.
+// A denied call is not, whatever its message says.
+func (e *APIError) notFound() bool {
+	if e.unauthenticated() || e.forbidden() {
+		return false
+	}
+	code := strings.ToLower(e.Code)
+	msg := strings.ToLower(e.Message)
+	return code == "404" ||
+		strings.Contains(code, "notfound") ||
+		strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "not exist")
+}
+
+// refNotFound reports whether the error is about a ref rather than a file.
+// Only the code and the subject of the message, its first word, are
+// looked at: the rest of the message may quote a file path, which can
+// contain any of the words.
+func (e *APIError) refNotFound() bool {
+	code := strings.ToLower(e.Code)
+	subject := strings.ToLower(strings.SplitN(strings.TrimSpace(e.Message), " ", 2)[0])
+	for _, word := range []string{"ref", "branch", "revision", "commit"} {
+		if strings.Contains(code, word) || strings.TrimRight(subject, ":") == word {
+			return true
+		}
+	}
+	return false
+}
+
+// responseError returns the error of a response body with the given fields.
+func responseError(code, message, requestId *string) error {
+	return &APIError{
+		Code:      tea.StringValue(code),
+		Message:   tea.StringValue(message),
+		RequestId: tea.StringValue(requestId),
+	}
+}
+
+// callError converts an error returned by the devops client.
+// SDK errors become *APIError, others are returned as is.
+func callError(err error) error {
+	var e *tea.SDKError
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	code := tea.StringValue(e.Code)
+	if code == "" && e.StatusCode != nil {
+		code = strconv.Itoa(tea.IntValue(e.StatusCode))
+	}
+	return &APIError{
+		Code:      code,
+		Message:   tea.StringValue(e.Message),
+		RequestId: sdkRequestId(e),
+		cause:     err,
+	}
+}
+
+// sdkRequestId returns the request id in the response body kept by e, if any.
+func sdkRequestId(e *tea.SDKError) string {
+	var data map[string]interface{}
+	if e.Data == nil || json.Unmarshal([]byte(*e.Data), &data) != nil {
+		return ""
+	}
+	for _, key := range []string{"RequestId", "requestId"} {
+		if id, ok := data[key].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// redactedError hides secrets in the message of err.
+//
+// Values of accessToken query parameters, as in request URLs, are always
+// hidden. Other occurrences of a secret are hidden only if it is at least
+// minRedactedLen long: a short secret may also be part of other words.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+const minRedactedLen = 8
+
+var accessTokenParam = regexp.MustCompile(`(?i)(accessToken=)[^&\s"']+`)
+
+func (e *redactedError) Error() string {
+	msg := accessTokenParam.ReplaceAllString(e.err.Error(), "${1}***")
+	for _, secret := range e.secrets {
+		if len(secret) >= minRedactedLen {
+			msg = strings.ReplaceAll(msg, secret, "***")
+		}
+	}
+	return msg
+}
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// ValidationError lists the problems found in the loaded migrations.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid migrations: " + strings.Join(e.Problems, "; ")
+}
@@ -0,0 +1,130 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		code, message string
+		want          []error
+		notWant       []error
+	}{
+		{"401", "", []error{ErrAPIFailure, ErrUnauthenticated}, []error{ErrForbidden, fs.ErrNotExist}},
+		{"InvalidAccessKeyId.NotFound", "", []error{ErrUnauthenticated}, []error{fs.ErrNotExist}},
+		{"403", "", []error{ErrForbidden}, []error{ErrUnauthenticated}},
+		{"AccessDenied", "Access denied for the repository", []error{ErrForbidden}, []error{fs.ErrNotExist}},
+		{"NotFound", "file not found", []error{ErrFileNotFound, fs.ErrNotExist}, []error{ErrRefNotFound}},
+		{"404", "branch not found", []error{ErrRefNotFound}, []error{ErrFileNotFound, fs.ErrNotExist}},
+		{"RefNotFound", "", []error{ErrRefNotFound}, []error{ErrFileNotFound}},
+		{"FileNotFound", "file not found: db/1_user_preferences.up.sql", []error{ErrFileNotFound, fs.ErrNotExist}, []error{ErrRefNotFound}},
+		{"404", "db/2_commit_log.up.sql does not exist", []error{ErrFileNotFound, fs.ErrNotExist}, []error{ErrRefNotFound}},
+		{"Forbidden.NotFound", "", []error{ErrForbidden}, []error{fs.ErrNotExist}},
+		{"500", "internal error", []error{ErrAPIFailure}, []error{ErrFileNotFound, ErrRefNotFound, ErrForbidden}},
+	}
+	for _, tt := range tests {
+		err := responseError(tea.String(tt.code), tea.String(tt.message), nil)
+		for _, target := range tt.want {
+			if !errors.Is(err, target) {
+				t.Errorf("%s %q is not %v", tt.code, tt.message, target)
+			}
+		}
+		for _, target := range tt.notWant {
+			if errors.Is(err, target) {
+				t.Errorf("%s %q is %v", tt.code, tt.message, target)
+			}
+		}
+	}
+}
+
+func TestCallError(t *testing.T) {
+	err := callError(sdkError("", 404, "file not exist"))
+	var e *APIError
+	if !errors.As(err, &e) {
+		t.Fatalf("callError = %T, want *APIError", err)
+	}
+	if e.Code != "404" || e.RequestId != "req-2" {
+		t.Errorf("Code, RequestId = %q, %q; want 404, req-2", e.Code, e.RequestId)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("404 file not exist is not fs.ErrNotExist")
+	}
+	var sdk *tea.SDKError
+	if !errors.As(err, &sdk) {
+		t.Error("the SDK error is not unwrapped")
+	}
+
+	other := errors.New("dial failed")
+	if got := callError(other); got != other {
+		t.Errorf("callError(%v) = %v, want it unchanged", other, got)
+	}
+}
+
+func TestRedactedError(t *testing.T) {
+	tests := []struct {
+		message string
+		secrets []string
+		want    string
+	}{
+		{"GET /tree?accessToken=abc&ref=master: failed", []string{"abc"}, "GET /tree?accessToken=***&ref=master: failed"},
+		{"ref master, project p: failed", []string{"t"}, "ref master, project p: failed"},
+		{"token token-0123456789 is invalid", []string{"token-0123456789"}, "token *** is invalid"},
+		{"url ?AccessToken=token-0123456789", []string{"token-0123456789"}, "url ?AccessToken=***"},
+		{"header pt-0123456789abcdef, url ?accessToken=abc", []string{"abc", "pt-0123456789abcdef"}, "header ***, url ?accessToken=***"},
+	}
+	for _, tt := range tests {
+		err := &redactedError{err: errors.New(tt.message), secrets: tt.secrets}
+		if got := err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestErrorContext checks that failed reads name the path, ref and project
+// but not the access token or personal access token.
+func TestErrorContext(t *testing.T) {
+	token, pat := "token-0123456789", "pat-0123456789abcdef"
+	tests := map[string]func(c *Config){
+		"access token":          func(c *Config) {},
+		"personal access token": func(c *Config) { c.AccessToken, c.PersonalAccessToken = "", pat },
+	}
+	for name, configure := range tests {
+		t.Run(name, func(t *testing.T) {
+			option := testOption("db")
+			configure(&option.Config)
+			client := newFakeClient(sampleFiles)
+			client.blobErr = func(ref, filePath string) error {
+				return sdkError("InvalidToken", 401, "invalid token "+token+" or "+pat+" in request ?accessToken="+token)
+			}
+			d := newTestDriver(t, client, option)
+
+			_, _, err := d.ReadUp(1)
+			if !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("err = %v, want ErrUnauthenticated", err)
+			}
+			msg := err.Error()
+			for _, want := range []string{"db/1_init.up.sql", "ref master", "project project", "organization org"} {
+				if !strings.Contains(msg, want) {
+					t.Errorf("error %q does not mention %q", msg, want)
+				}
+			}
+			for _, secret := range []string{option.Config.AccessToken, option.Config.PersonalAccessToken} {
+				if secret != "" && strings.Contains(msg, secret) {
+					t.Errorf("error %q contains the token %q", msg, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := &ValidationError{Problems: []string{"gap after 1", "duplicate 3"}}
+	if got, want := err.Error(), "invalid migrations: gap after 1; duplicate 3"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,514 @@
+package codeup
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	iurl "net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// fakeClient is an in-memory CodeUp repository implementing Client.
+// Files are kept by ref and repo path; directories are implied by them.
+type fakeClient struct {
+	mu    sync.Mutex
+	refs  map[string]map[string]string
+	tags  map[string]bool // refs that are tags rather than branches.
+	calls []fakeCall
+
+	// treeErr and blobErr, if set, are called before each listing and
+	// file read; a non-nil error fails the call.
+	treeErr func(ref, dir string) error
+	blobErr func(ref, filePath string) error
+
+	// entries, if set, replaces the listing of a directory.
+	entries func(ref, dir string) []*devops.ListRepositoryTreeResponseBodyResult
+
+	// delay is slept by each file read.
+	delay time.Duration
+}
+
+// fakeCall is a call received by fakeClient.
+type fakeCall struct {
+	action  string
+	path    string
+	ref     string
+	typ     string
+	headers map[string]string
+}
+
+// newFakeClient returns a fake repository with files at ref "master".
+func newFakeClient(files map[string]string) *fakeClient {
+	c := &fakeClient{refs: map[string]map[string]string{}}
+	for p, content := range files {
+		c.set("master", p, content)
+	}
+	return c
+}
+
+// set writes the file at p in ref, creating ref if needed.
+func (c *fakeClient) set(ref, p, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refs[ref] == nil {
+		c.refs[ref] = map[string]string{}
+	}
+	c.refs[ref][p] = content
+}
+
+// remove deletes the file at p in ref.
+func (c *fakeClient) remove(ref, p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refs[ref], p)
+}
+
+// count returns the number of calls of action received.
+func (c *fakeClient) count(action string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, call := range c.calls {
+		if call.action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// received returns the calls of action received.
+func (c *fakeClient) received(action string) []fakeCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var calls []fakeCall
+	for _, call := range c.calls {
+		if call.action == action {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// reset forgets the calls received.
+func (c *fakeClient) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+func (c *fakeClient) record(action, p, ref, typ string, headers map[string]*string) {
+	h := make(map[string]string)
+	for k, v := range headers {
+		h[k] = tea.StringValue(v)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, fakeCall{action: action, path: p, ref: ref, typ: typ, headers: h})
+}
+
+// fakeBlobId returns the git blob id of content.
+func fakeBlobId(content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// list returns the entries under dir at ref, or ok false if ref does not exist.
+func (c *fakeClient) list(ref, dir string, recursive bool) (entries []*devops.ListRepositoryTreeResponseBodyResult, ok bool) {
+	if c.entries != nil {
+		if entries := c.entries(ref, dir); entries != nil {
+			return entries, true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, ok := c.refs[ref]
+	if !ok {
+		return nil, false
+	}
+
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	dirs := make(map[string]bool)
+	for p, content := range files {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(p, prefix)
+		if i := strings.IndexByte(rest, '/'); i >= 0 && !recursive {
+			dirs[rest[:i]] = true
+			continue
+		}
+		if recursive {
+			for d := path.Dir(rest); d != "."; d = path.Dir(d) {
+				dirs[d] = true
+			}
+		}
+		entries = append(entries, &devops.ListRepositoryTreeResponseBodyResult{
+			Id:   tea.String(fakeBlobId(content)),
+			Mode: tea.String("100644"),
+			Name: tea.String(path.Base(p)),
+			Path: tea.String(p),
+			Type: tea.String("blob"),
+		})
+	}
+	for d := range dirs {
+		entries = append(entries, &devops.ListRepositoryTreeResponseBodyResult{
+			Mode: tea.String("040000"),
+			Name: tea.String(path.Base(d)),
+			Path: tea.String(prefix + d),
+			Type: tea.String("tree"),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return tea.StringValue(entries[i].Path) < tea.StringValue(entries[j].Path)
+	})
+	return entries, true
+}
+
+func (c *fakeClient) listResponse(request *devops.ListRepositoryTreeRequest) ([]*devops.ListRepositoryTreeResponseBodyResult, *devops.ListRepositoryTreeResponse, error) {
+	ref, dir := tea.StringValue(request.RefName), tea.StringValue(request.Path)
+	if c.treeErr != nil {
+		if err := c.treeErr(ref, dir); err != nil {
+			return nil, nil, err
+		}
+	}
+	entries, ok := c.list(ref, dir, tea.StringValue(request.Type) == "RECURSIVE")
+	if !ok {
+		return nil, &devops.ListRepositoryTreeResponse{Body: &devops.ListRepositoryTreeResponseBody{
+			Success:      tea.Bool(false),
+			ErrorCode:    tea.String("NotFound"),
+			ErrorMessage: tea.String("ref not found: " + ref),
+		}}, nil
+	}
+	return entries, nil, nil
+}
+
+func (c *fakeClient) ListRepositoryTreeWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.ListRepositoryTreeResponse, error) {
+	c.record("ListRepositoryTree", tea.StringValue(request.Path), tea.StringValue(request.RefName), tea.StringValue(request.Type), headers)
+	entries, failed, err := c.listResponse(request)
+	if err != nil || failed != nil {
+		return failed, err
+	}
+	return &devops.ListRepositoryTreeResponse{Body: &devops.ListRepositoryTreeResponseBody{
+		Success: tea.Bool(true),
+		Result:  entries,
+	}}, nil
+}
+
+func (c *fakeClient) GetFileBlobsWithOptions(repositoryId *string, request *devops.GetFileBlobsRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileBlobsResponse, error) {
+	ref, p := tea.StringValue(request.Ref), tea.StringValue(request.FilePath)
+	c.record("GetFileBlobs", p, ref, "", headers)
+	if c.delay > 0 {
+		time.Sleep(c.delay)
+	}
+	if c.blobErr != nil {
+		if err := c.blobErr(ref, p); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	files, refOk := c.refs[ref]
+	content, fileOk := files[p]
+	c.mu.Unlock()
+	switch {
+	case !refOk:
+		return failedBlob("NotFound", "ref not found: "+ref), nil
+	case !fileOk:
+		return failedBlob("FileNotFound", "file not found: "+p), nil
+	}
+	return &devops.GetFileBlobsResponse{Body: &devops.GetFileBlobsResponseBody{
+		Success: tea.Bool(true),
+		Result:  &devops.GetFileBlobsResponseBodyResult{Content: tea.String(content)},
+	}}, nil
+}
+
+func failedBlob(code, message string) *devops.GetFileBlobsResponse {
+	return &devops.GetFileBlobsResponse{Body: &devops.GetFileBlobsResponseBody{
+		Success:      tea.Bool(false),
+		ErrorCode:    tea.String(code),
+		ErrorMessage: tea.String(message),
+		RequestId:    tea.String("req-1"),
+	}}
+}
+
+// sdkError returns the error the devops client returns for a failed call.
+func sdkError(code string, status int, message string) error {
+	return tea.NewSDKError(map[string]interface{}{
+		"code":       code,
+		"statusCode": status,
+		"message":    message,
+		"data":       map[string]interface{}{"RequestId": "req-2"},
+	})
+}
+
+// pagedFake is a fakeClient listing trees page by page,
+// by token if tokens is set or else by page number.
+type pagedFake struct {
+	*fakeClient
+	tokens bool
+}
+
+func (c pagedFake) ListRepositoryTreePageWithOptions(repositoryId *string, request *devops.ListRepositoryTreeRequest, page, pageSize int, token string, headers map[string]*string, runtime *service.RuntimeOptions) (*treePage, error) {
+	c.record("ListRepositoryTreePage", tea.StringValue(request.Path), tea.StringValue(request.RefName), tea.StringValue(request.Type), headers)
+	entries, failed, err := c.listResponse(request)
+	if err != nil {
+		return nil, err
+	}
+	if failed != nil {
+		return &treePage{ListRepositoryTreeResponse: failed}, nil
+	}
+
+	start := (page - 1) * pageSize
+	if c.tokens {
+		start = 0
+		if token != "" {
+			start, _ = strconv.Atoi(token)
+		}
+	}
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	resp := &treePage{ListRepositoryTreeResponse: &devops.ListRepositoryTreeResponse{Body: &devops.ListRepositoryTreeResponseBody{
+		Success: tea.Bool(true),
+		Result:  entries[start:end],
+	}}}
+	if c.tokens && end < len(entries) {
+		resp.nextToken = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+// refFake is a fakeClient that can look up its refs.
+type refFake struct {
+	*fakeClient
+}
+
+func (c refFake) lookup(action, ref string, ok func(string) bool) bool {
+	c.record(action, "", ref, "", nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.refs[ref]
+	return found && ok(ref)
+}
+
+func (c refFake) GetBranchInfoWithOptions(repositoryId *string, request *devops.GetBranchInfoRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetBranchInfoResponse, error) {
+	found := c.lookup("GetBranchInfo", tea.StringValue(request.BranchName), func(ref string) bool { return !c.tags[ref] && !isCommitId(ref) })
+	return &devops.GetBranchInfoResponse{Body: &devops.GetBranchInfoResponseBody{Success: tea.Bool(found), ErrorCode: notFoundCode(found)}}, nil
+}
+
+func (c refFake) GetRepositoryTagWithOptions(repositoryId *string, request *devops.GetRepositoryTagRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryTagResponse, error) {
+	found := c.lookup("GetRepositoryTag", tea.StringValue(request.TagName), func(ref string) bool { return c.tags[ref] })
+	return &devops.GetRepositoryTagResponse{Body: &devops.GetRepositoryTagResponseBody{Success: tea.Bool(found), ErrorCode: notFoundCode(found)}}, nil
+}
+
+func (c refFake) GetRepositoryCommitWithOptions(repositoryId *string, sha *string, request *devops.GetRepositoryCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryCommitResponse, error) {
+	found := c.lookup("GetRepositoryCommit", tea.StringValue(sha), isCommitId)
+	return &devops.GetRepositoryCommitResponse{Body: &devops.GetRepositoryCommitResponseBody{Success: tea.Bool(found), ErrorCode: notFoundCode(found)}}, nil
+}
+
+func (c refFake) GetFileLastCommitWithOptions(repositoryId *string, request *devops.GetFileLastCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetFileLastCommitResponse, error) {
+	ref, p := tea.StringValue(request.Sha), tea.StringValue(request.FilePath)
+	c.record("GetFileLastCommit", p, ref, "", headers)
+	c.mu.Lock()
+	content, ok := c.refs[ref][p]
+	c.mu.Unlock()
+	if !ok {
+		return &devops.GetFileLastCommitResponse{Body: &devops.GetFileLastCommitResponseBody{
+			Success:      tea.Bool(false),
+			ErrorCode:    tea.String("NotFound"),
+			ErrorMessage: tea.String("file not found"),
+		}}, nil
+	}
+	return &devops.GetFileLastCommitResponse{Body: &devops.GetFileLastCommitResponseBody{
+		Success: tea.Bool(true),
+		Result:  &devops.GetFileLastCommitResponseBodyResult{Id: tea.String("commit-" + fakeBlobId(content)[:7])},
+	}}, nil
+}
+
+func notFoundCode(found bool) *string {
+	if found {
+		return nil
+	}
+	return tea.String("NotFound")
+}
+
+// fakeClock is a Clock whose time only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	waits   []time.Duration // durations waited for, in order.
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// advance moves the time forward by d, firing the waits that are due.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// waited returns the durations waited for so far.
+func (c *fakeClock) waited() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// pending returns the number of waits not fired yet.
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// testOption returns the option of a test driver reading path at master.
+func testOption(p string) Option {
+	return NewOption(Config{
+		ProjectId:      "project",
+		OrganizationId: "org",
+		AccessToken:    "token-0123456789",
+		Path:           p,
+		Ref:            "master",
+	})
+}
+
+// newTestDriver opens a driver on client, closed when the test ends.
+func newTestDriver(t *testing.T, client Client, option Option) *CodeUp {
+	t.Helper()
+	d, err := WithInstance(client, option)
+	if err != nil {
+		t.Fatalf("WithInstance: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d.(*CodeUp)
+}
+
+// mustParseUrl parses the URL s.
+func mustParseUrl(t *testing.T, s string) *iurl.URL {
+	t.Helper()
+	u, err := iurl.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// readBody returns the content of a body returned with err.
+func readBody(t *testing.T, r io.ReadCloser, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(b)
+}
+
+// readUp returns the up migration body of version.
+func readUp(t *testing.T, d *CodeUp, version uint) string {
+	t.Helper()
+	r, _, err := d.ReadUp(version)
+	return readBody(t, r, err)
+}
+
+// readDown returns the down migration body of version.
+func readDown(t *testing.T, d *CodeUp, version uint) string {
+	t.Helper()
+	r, _, err := d.ReadDown(version)
+	return readBody(t, r, err)
+}
+
+// eventLog is a Logger keeping the events it receives.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) Log(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+// ops returns the events of op.
+func (l *eventLog) ops(op string) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []Event
+	for _, e := range l.events {
+		if e.Op == op {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// sampleFiles is a small migration directory "db".
+var sampleFiles = map[string]string{
+	"db/1_init.up.sql":     "CREATE TABLE a;",
+	"db/1_init.down.sql":   "DROP TABLE a;",
+	"db/2_users.up.sql":    "CREATE TABLE users;",
+	"db/2_users.down.sql":  "DROP TABLE users;",
+	"db/3_index.up.sql":    "CREATE INDEX i;",
+	"other/9_other.up.sql": "SELECT 9;",
+}
@@ -0,0 +1,174 @@
+package codeup
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS returns a read-only view of the loaded migrations as a file system.
+// Files are named by their raw name, like "1_init.up.sql",
+// and their contents are read when they are opened.
+func (s *CodeUp) FS() fs.FS {
+	return migrationFS{s}
+}
+
+type migrationFS struct {
+	s *CodeUp
+}
+
+func (f migrationFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.s.loaded(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	f.s.mu.RLock()
+	m, ok := f.s.files[name]
+	var children []string
+	if !ok {
+		children = f.children(name)
+	}
+	f.s.mu.RUnlock()
+
+	if ok {
+		r, err := f.s.open(f.s.ctx, m.Migration)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return &fsFile{
+			info:   fsInfo{name: path.Base(name), size: int64(len(content))},
+			Reader: strings.NewReader(string(content)),
+		}, nil
+	}
+	if name == "." || len(children) != 0 {
+		return &fsDir{info: fsInfo{name: path.Base(name), dir: true}, entries: children, fsys: f, path: name}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// children returns the sorted names directly under dir, with s.mu held.
+func (f migrationFS) children(dir string) []string {
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for raw := range f.s.files {
+		if !strings.HasPrefix(raw, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(raw, prefix)
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fsInfo is the fs.FileInfo of a migration file or directory.
+type fsInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i fsInfo) Name() string       { return i.name }
+func (i fsInfo) Size() int64        { return i.size }
+func (i fsInfo) ModTime() time.Time { return time.Time{} }
+func (i fsInfo) IsDir() bool        { return i.dir }
+func (i fsInfo) Sys() any           { return nil }
+
+func (i fsInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// fsEntry is a fs.DirEntry of a migration file or directory.
+type fsEntry struct {
+	fsInfo
+	fsys migrationFS
+	path string
+}
+
+func (e fsEntry) Type() fs.FileMode { return e.Mode().Type() }
+
+// Info returns the info of the entry.
+// The size of a file is only known once it is read, so the file is read.
+func (e fsEntry) Info() (fs.FileInfo, error) {
+	if e.dir {
+		return e.fsInfo, nil
+	}
+	f, err := e.fsys.Open(e.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// fsFile is an opened migration file.
+type fsFile struct {
+	info fsInfo
+	*strings.Reader
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *fsFile) Close() error { return nil }
+
+// fsDir is an opened directory of migration files.
+type fsDir struct {
+	info    fsInfo
+	entries []string // names not read yet by ReadDir.
+	fsys    migrationFS
+	path    string
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: fs.ErrInvalid}
+}
+
+func (d *fsDir) Close() error { return nil }
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	names := d.entries
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	d.entries = d.entries[len(names):]
+
+	d.fsys.s.mu.RLock()
+	defer d.fsys.s.mu.RUnlock()
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		p := path.Join(d.path, name)
+		_, isFile := d.fsys.s.files[p]
+		entries = append(entries, fsEntry{fsInfo: fsInfo{name: name, dir: !isFile}, fsys: d.fsys, path: p})
+	}
+	return entries, nil
+}
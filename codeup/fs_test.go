@@ -0,0 +1,53 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	files := map[string]string{
+		"db/1_init.up.sql":       "CREATE TABLE a;",
+		"db/1_init.down.sql":     "DROP TABLE a;",
+		"db/v2/2_users.up.sql":   "CREATE TABLE users;",
+		"db/v2/x/3_index.up.sql": "CREATE INDEX i;",
+	}
+	option := testOption("db")
+	option.Config.Recursive = true
+	d := newTestDriver(t, newFakeClient(files), option)
+	fsys := d.FS()
+
+	if err := fstest.TestFS(fsys, "1_init.up.sql", "1_init.down.sql", "v2/2_users.up.sql", "v2/x/3_index.up.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := fs.ReadFile(fsys, "v2/2_users.up.sql")
+	if err != nil || string(b) != "CREATE TABLE users;" {
+		t.Errorf("ReadFile = %q, %v", b, err)
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"1_init.down.sql", "1_init.up.sql", "v2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir(.) = %v, want %v", names, want)
+	}
+
+	for name, want := range map[string]error{"../db": fs.ErrInvalid, "/1_init.up.sql": fs.ErrInvalid, "4_none.up.sql": fs.ErrNotExist} {
+		if _, err := fsys.Open(name); !errors.Is(err, want) {
+			t.Errorf("Open(%q): err = %v, want %v", name, err, want)
+		}
+	}
+
+	d.Close()
+	if _, err := fsys.Open("."); !errors.Is(err, ErrClosed) {
+		t.Errorf("Open after Close: err = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,76 @@
+package codeup
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// globDirs returns the directories under the listing path at ref
+// matching Config.PathGlob, in order. They are found by one recursive
+// listing below the part of the pattern without wildcards.
+func (s *CodeUp) globDirs(ctx context.Context, ref string) ([]string, error) {
+	glob := cleanPath(s.option.Config.PathGlob)
+	var static []string
+	for _, part := range strings.Split(glob, "/") {
+		if strings.ContainsAny(part, `*?[\`) {
+			break
+		}
+		static = append(static, part)
+	}
+
+	base := s.option.Config.listPath()
+	request := s.treeRequest(ref, path.Join(base, strings.Join(static, "/")))
+	request.Type = tea.String("RECURSIVE")
+	entries, err := s.listTree(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Directories are taken from the paths of the files too,
+	// in case the listing has no entries for them.
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		for dir != "." && dir != "" && !seen[dir] {
+			seen[dir] = true
+			if ok, _ := path.Match(glob, dir); ok {
+				dirs = append(dirs, dir)
+			}
+			dir = path.Dir(dir)
+		}
+	}
+	for _, v := range entries {
+		name, ok := relativePath(base, tea.StringValue(v.Path))
+		if !ok {
+			continue
+		}
+		if tea.StringValue(v.Type) == "tree" {
+			add(name)
+		} else {
+			add(path.Dir(name))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// appendNew returns dirs followed by those of more not in dirs,
+// without changing dirs.
+func appendNew(dirs, more []string) []string {
+	out := append([]string(nil), dirs...)
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		seen[cleanPath(dir)] = true
+	}
+	for _, dir := range more {
+		if !seen[cleanPath(dir)] {
+			seen[cleanPath(dir)] = true
+			out = append(out, dir)
+		}
+	}
+	return out
+}
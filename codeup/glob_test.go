@@ -0,0 +1,17 @@
+package codeup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAppendNew(t *testing.T) {
+	dirs := []string{"a", "b"}
+	got := appendNew(dirs, []string{"/b/", "c", "a", "c"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appendNew = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(dirs, []string{"a", "b"}) {
+		t.Errorf("appendNew changed dirs to %v", dirs)
+	}
+}
@@ -0,0 +1,41 @@
+package codeup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gzipSuffix marks migration files stored gzip compressed, like "1_init.up.sql.gz".
+const gzipSuffix = ".gz"
+
+// isGzip reports whether the file with the raw name and content is gzip compressed,
+// by its name or by the gzip magic number.
+func isGzip(raw, content string) bool {
+	return strings.HasSuffix(raw, gzipSuffix) || strings.HasPrefix(content, "\x1f\x8b")
+}
+
+// gunzip returns the decompressed content of the file with the raw name.
+// The decompressed size is limited by Option.MaxSize.
+func (s *CodeUp) gunzip(raw, content string) (string, error) {
+	zr, err := gzip.NewReader(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("decompress %s: %w", raw, err)
+	}
+	defer zr.Close()
+
+	var r io.Reader = zr
+	if max := s.option.MaxSize; max > 0 {
+		r = io.LimitReader(zr, max+1)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return "", fmt.Errorf("decompress %s: %w", raw, err)
+	}
+	if max := s.option.MaxSize; max > 0 && int64(buf.Len()) > max {
+		return "", fmt.Errorf("%w: %s is over %d bytes decompressed", ErrTooLarge, raw, max)
+	}
+	return buf.String(), nil
+}
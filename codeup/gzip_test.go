@@ -0,0 +1,60 @@
+package codeup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// gzipped returns s gzip compressed.
+func gzipped(s string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.String()
+}
+
+func TestIsGzip(t *testing.T) {
+	tests := []struct {
+		raw, content string
+		want         bool
+	}{
+		{"1_a.up.sql", "SELECT 1;", false},
+		{"1_a.up.sql.gz", "", true},
+		{"1_a.up.sql", gzipped("SELECT 1;"), true},
+	}
+	for _, tt := range tests {
+		if got := isGzip(tt.raw, tt.content); got != tt.want {
+			t.Errorf("isGzip(%q, %q) = %v, want %v", tt.raw, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat("INSERT INTO t VALUES (1);\n", 100)
+	client := newFakeClient(map[string]string{
+		"db/1_a.up.sql":    gzipped(body),
+		"db/2_b.up.sql.gz": gzipped(body),
+		"db/3_c.up.sql.gz": "not gzip",
+	})
+	option := testOption("db")
+	option.MaxSize = int64(len(body))
+	d := newTestDriver(t, client, option)
+
+	for _, v := range []uint{1, 2} {
+		if got := readUp(t, d, v); got != body {
+			t.Errorf("ReadUp(%d) = %q, want %q", v, got, body)
+		}
+	}
+	if _, _, err := d.ReadUp(3); err == nil {
+		t.Error("read of a broken gzip file succeeded")
+	}
+
+	d.option.MaxSize = int64(len(body)) - 1
+	if _, err := d.gunzip("1_a.up.sql", gzipped(body)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("gunzip over MaxSize: err = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,44 @@
+package codeup
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// ReadByIdentifier returns the migration body of the version whose
+// identifier, like "create_users", is identifier, in direction.
+// It fails if several versions have the identifier.
+func (s *CodeUp) ReadByIdentifier(identifier string, direction source.Direction) (r io.ReadCloser, version uint, err error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var found *source.Migration
+	v, ok := ms.First()
+	for ok {
+		if m := byVersion(ms, v, direction); m != nil && m.Identifier == identifier {
+			if found != nil {
+				return nil, 0, fmt.Errorf("identifier %q is ambiguous: versions %d and %d", identifier, found.Version, v)
+			}
+			found = m
+		}
+		v, ok = ms.Next(v)
+	}
+	if found == nil {
+		return nil, 0, &fs.PathError{
+			Op:   "read " + string(direction) + " identifier " + identifier,
+			Path: s.option.Config.Path,
+			Err:  fs.ErrNotExist,
+		}
+	}
+
+	r, err = s.open(s.ctx, found)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, found.Version, nil
+}
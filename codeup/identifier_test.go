@@ -0,0 +1,31 @@
+package codeup
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestReadByIdentifier(t *testing.T) {
+	files := map[string]string{
+		"db/1_users.up.sql":   "CREATE TABLE users;",
+		"db/1_users.down.sql": "DROP TABLE users;",
+		"db/2_seed.up.sql":    "INSERT 2;",
+		"db/3_seed.up.sql":    "INSERT 3;",
+	}
+	d := newTestDriver(t, newFakeClient(files), testOption("db"))
+
+	r, v, err := d.ReadByIdentifier("users", source.Down)
+	if got := readBody(t, r, err); got != "DROP TABLE users;" || v != 1 {
+		t.Errorf("ReadByIdentifier(users) = %q, %d", got, v)
+	}
+	if _, _, err := d.ReadByIdentifier("seed", source.Up); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ReadByIdentifier(seed): err = %v, want ambiguous", err)
+	}
+	if _, _, err := d.ReadByIdentifier("seed", source.Down); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadByIdentifier(seed, down): err = %v, want fs.ErrNotExist", err)
+	}
+}
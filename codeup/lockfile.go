@@ -0,0 +1,70 @@
+package codeup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// readLockfile returns the commits pinned by Config.Lockfile,
+// a JSON object from versions to commit ids, like:
+//
+//	{"1": "2f3c1e0", "2": "9a41b7d"}
+func (s *CodeUp) readLockfile(ctx context.Context) (map[uint]string, error) {
+	name := s.option.Config.Lockfile
+	content, err := s.fetchRef(ctx, s.blobPath(name), s.option.Config.listRef())
+	if err != nil {
+		return nil, err
+	}
+
+	var pins map[uint]string
+	if err := json.Unmarshal([]byte(content), &pins); err != nil {
+		return nil, fmt.Errorf("parse lockfile %s: %w", name, err)
+	}
+	return pins, nil
+}
+
+// pin sets the commit each of files is read at to the one its version is
+// pinned to by Config.Lockfile; the ref it was listed at is kept. Versions listed but not pinned, and pinned but not
+// listed, have drifted from the lockfile and are reported in a *ValidationError.
+func (s *CodeUp) pin(ctx context.Context, files []*file) error {
+	pins, err := s.readLockfile(ctx)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	listed := make(map[uint]bool)
+	for _, f := range files {
+		if !s.option.Config.inRange(f.Version) || !s.option.Config.allowed(f.Version) {
+			continue
+		}
+		listed[f.Version] = true
+		commit, ok := pins[f.Version]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("version %d is not pinned in %s: %s", f.Version, s.option.Config.Lockfile, f.Raw))
+			continue
+		}
+		if commit != f.ref {
+			// The blob listed is not known to be the one at commit.
+			f.pinned, f.id = commit, ""
+		}
+	}
+
+	var missing []uint
+	for v := range pins {
+		if !listed[v] && s.option.Config.inRange(v) && s.option.Config.allowed(v) {
+			missing = append(missing, v)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for _, v := range missing {
+		problems = append(problems, fmt.Sprintf("version %d is pinned in %s but not found", v, s.option.Config.Lockfile))
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package codeup
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"db/lock.json":    `{"1": "abc1234", "2": "master"}`,
+		"db/1_a.up.sql":   "CREATE TABLE a2;",
+		"db/2_b.up.sql":   "CREATE TABLE b;",
+		"db/2_b.down.sql": "DROP TABLE b;",
+	})
+	client.set("abc1234", "db/1_a.up.sql", "CREATE TABLE a;")
+	// Pinned files are still of the ref they were listed at.
+	for _, active := range []string{"", "master"} {
+		option := testOption("db")
+		option.Config.Lockfile = "lock.json"
+		option.Config.ActiveRef = active
+		option.CacheContents = true
+		d := newTestDriver(t, client, option)
+
+		if got := d.Versions(); !reflect.DeepEqual(got, []uint{1, 2}) {
+			t.Fatalf("ActiveRef %q: Versions() = %v, want [1 2]", active, got)
+		}
+		if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+			t.Errorf("ActiveRef %q: ReadUp(1) = %q, want the body at the pinned commit", active, got)
+		}
+		if ref, _ := d.RefOf(1); ref != "abc1234" {
+			t.Errorf("ActiveRef %q: RefOf(1) = %q, want abc1234", active, ref)
+		}
+		if got := readUp(t, d, 2); got != "CREATE TABLE b;" {
+			t.Errorf("ActiveRef %q: ReadUp(2) = %q", active, got)
+		}
+	}
+}
+
+func TestLockfileDrift(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"db/lock.json":  `{"1": "master", "3": "master", "9": "master"}`,
+		"db/1_a.up.sql": "",
+		"db/2_b.up.sql": "",
+		"db/3_c.up.sql": "",
+	})
+	option := testOption("db")
+	option.Config.Lockfile = "lock.json"
+	option.Config.MaxVersion = 8
+	_, err := WithInstance(client, option)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	if len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "version 2 is not pinned") {
+		t.Errorf("problems = %q, want only version 2 unpinned", verr.Problems)
+	}
+}
+
+func TestLockfileInvalid(t *testing.T) {
+	client := newFakeClient(map[string]string{"db/lock.json": `["master"]`, "db/1_a.up.sql": ""})
+	option := testOption("db")
+	option.Config.Lockfile = "lock.json"
+	if _, err := WithInstance(client, option); err == nil || !strings.Contains(err.Error(), "parse lockfile") {
+		t.Errorf("err = %v, want a parse error", err)
+	}
+	if got := (&ValidationError{Problems: []string{"a", "b"}}).Error(); !reflect.DeepEqual(got, "invalid migrations: a; b") {
+		t.Errorf("ValidationError = %q", got)
+	}
+}
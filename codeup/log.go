@@ -0,0 +1,37 @@
+package codeup
+
+// Ops of Event.
+const (
+	OpList   = "list"   // a directory is listed.
+	OpRead   = "read"   // a file is read.
+	OpSkip   = "skip"   // a tree entry is not loaded as a migration.
+	OpReload = "reload" // the migrations are reloaded in the background.
+)
+
+// Event describes an API call or a decision of the driver.
+type Event struct {
+	Op     string
+	Path   string
+	Ref    string
+	Count  int    // number of entries listed, for OpList.
+	Reason string // why the entry is skipped, for OpSkip.
+	Err    error  // error of the call, if any.
+}
+
+// Logger receives the events of the driver.
+// Log may be called concurrently.
+type Logger interface {
+	Log(e Event)
+}
+
+// LoggerFunc adapts a function to Logger.
+type LoggerFunc func(e Event)
+
+// Log calls f(e).
+func (f LoggerFunc) Log(e Event) { f(e) }
+
+func (s *CodeUp) log(e Event) {
+	if s.option.Logger != nil {
+		s.option.Logger.Log(e)
+	}
+}
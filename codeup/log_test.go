@@ -0,0 +1,31 @@
+package codeup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	var events []Event
+	option := testOption("db")
+	option.Logger = LoggerFunc(func(e Event) { events = append(events, e) })
+	option.Config.MaxVersion = 2
+	d := newTestDriver(t, client, option)
+	readUp(t, d, 1)
+	client.remove("master", "db/2_users.up.sql")
+	d.ReadUp(2)
+
+	want := []Event{
+		{Op: OpList, Path: "db", Ref: "master", Count: 5},
+		{Op: OpSkip, Path: "3_index.up.sql", Reason: "out of version range"},
+		{Op: OpRead, Path: "db/1_init.up.sql", Ref: "master"},
+	}
+	if len(events) != 4 || !reflect.DeepEqual(events[:3], want) {
+		t.Fatalf("events = %+v, want %+v and a failed read", events, want)
+	}
+	if e := events[3]; e.Op != OpRead || e.Path != "db/2_users.up.sql" || !errors.Is(e.Err, ErrFileNotFound) {
+		t.Errorf("failed read event = %+v", e)
+	}
+}
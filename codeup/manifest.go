@@ -0,0 +1,57 @@
+package codeup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// manifestEntry is a version listed in a manifest file, like:
+//
+//	[{"version": 1, "identifier": "init", "up": "1_init.up.sql", "down": "1_init.down.sql"}]
+//
+// File names are relative to Config.Path, either of them may be omitted.
+type manifestEntry struct {
+	Version    uint   `json:"version"`
+	Identifier string `json:"identifier"`
+	Up         string `json:"up"`
+	Down       string `json:"down"`
+}
+
+// readManifest returns the migration files listed in Config.Manifest,
+// without listing the repo tree.
+func (s *CodeUp) readManifest(ctx context.Context) ([]*file, error) {
+	name := s.option.Config.Manifest
+	ref := s.option.Config.listRef()
+	content, err := s.fetchRef(ctx, s.blobPath(name), ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal([]byte(content), &entries); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", name, err)
+	}
+
+	var files []*file
+	for _, e := range entries {
+		for _, f := range []struct {
+			raw       string
+			direction source.Direction
+		}{{e.Up, source.Up}, {e.Down, source.Down}} {
+			if f.raw == "" {
+				continue
+			}
+			m := &source.Migration{
+				Version:    e.Version,
+				Identifier: e.Identifier,
+				Direction:  f.direction,
+				Raw:        cleanPath(f.raw),
+			}
+			files = append(files, &file{Migration: m, ref: ref})
+		}
+	}
+	return files, nil
+}
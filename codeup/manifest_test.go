@@ -0,0 +1,50 @@
+package codeup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	files := map[string]string{
+		"db/manifest.json": `[
+			{"version": 1, "identifier": "init", "up": "sql/init_up.sql", "down": "sql/init_down.sql"},
+			{"version": 2, "identifier": "users", "up": "/sql/users.sql"}
+		]`,
+		"db/sql/init_up.sql":   "CREATE TABLE a;",
+		"db/sql/init_down.sql": "DROP TABLE a;",
+		"db/sql/users.sql":     "CREATE TABLE users;",
+	}
+	client := newFakeClient(files)
+	option := testOption("db")
+	option.Config.Manifest = "manifest.json"
+	d := newTestDriver(t, client, option)
+
+	if got := d.Versions(); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Errorf("Versions() = %v, want [1 2]", got)
+	}
+	r, id, err := d.ReadUp(2)
+	if got := readBody(t, r, err); got != "CREATE TABLE users;" || id != "users" {
+		t.Errorf("ReadUp(2) = %q, %q", got, id)
+	}
+	if got := readDown(t, d, 1); got != "DROP TABLE a;" {
+		t.Errorf("ReadDown(1) = %q", got)
+	}
+	if n := client.count("ListRepositoryTree"); n != 0 {
+		t.Errorf("%d tree listings with a manifest, want 0", n)
+	}
+}
+
+func TestManifestInvalid(t *testing.T) {
+	for name, content := range map[string]string{"not json": "{", "missing": ""} {
+		files := map[string]string{"db/1_a.up.sql": ""}
+		if name != "missing" {
+			files["db/manifest.json"] = content
+		}
+		option := testOption("db")
+		option.Config.Manifest = "manifest.json"
+		if _, err := WithInstance(newFakeClient(files), option); err == nil {
+			t.Errorf("%s manifest: loading succeeded", name)
+		}
+	}
+}
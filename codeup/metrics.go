@@ -0,0 +1,14 @@
+package codeup
+
+// Metrics receives the counters of the driver.
+type Metrics interface {
+	// IncAPICall is called before each API call with its action name,
+	// e.g. "ListRepositoryTree" or "GetFileBlobs".
+	IncAPICall(action string)
+
+	// IncCacheHit is called when a read is served from the cache.
+	IncCacheHit()
+
+	// IncError is called when listing (OpList) or reading (OpRead) fails.
+	IncError(op string)
+}
@@ -0,0 +1,60 @@
+package codeup
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// countMetrics is a Metrics counting the calls it receives.
+type countMetrics struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	hits   int
+	errors map[string]int
+}
+
+func (m *countMetrics) IncAPICall(action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[action]++
+}
+
+func (m *countMetrics) IncCacheHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits++
+}
+
+func (m *countMetrics) IncError(op string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[op]++
+}
+
+func TestMetrics(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	m := &countMetrics{calls: map[string]int{}, errors: map[string]int{}}
+	option := testOption("db")
+	option.Metrics = m
+	option.CacheContents = true
+	d := newTestDriver(t, client, option)
+
+	readUp(t, d, 1)
+	readUp(t, d, 1)
+	client.remove("master", "db/2_users.up.sql")
+	if _, _, err := d.ReadUp(2); err == nil {
+		t.Fatal("read of a removed file succeeded")
+	}
+
+	want := map[string]int{"ListRepositoryTree": 1, "GetFileBlobs": 2}
+	if !reflect.DeepEqual(m.calls, want) {
+		t.Errorf("API calls = %v, want %v", m.calls, want)
+	}
+	if m.hits != 1 {
+		t.Errorf("cache hits = %d, want 1", m.hits)
+	}
+	if want := map[string]int{OpRead: 1}; !reflect.DeepEqual(m.errors, want) {
+		t.Errorf("errors = %v, want %v", m.errors, want)
+	}
+}
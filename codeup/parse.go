@@ -0,0 +1,153 @@
+package codeup
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// Parser parses migration file names, like source.Parse.
+// Parse may return a nil migration and no error to skip a file.
+type Parser interface {
+	Parse(name string) (*source.Migration, error)
+}
+
+// ParserFunc adapts a function, like source.Parse, to Parser.
+type ParserFunc func(name string) (*source.Migration, error)
+
+// Parse calls f(name).
+func (f ParserFunc) Parse(name string) (*source.Migration, error) { return f(name) }
+
+var versionPattern = regexp.MustCompile(`^([0-9]+)_(.*)$`)
+
+// parse parses the migration file name, without Config.StripPrefix
+// and the suffix of gzip compressed files.
+// ok is false if the file should be skipped.
+func (s *CodeUp) parse(name string) (m *source.Migration, ok bool, err error) {
+	m, ok, err = s.parseName(name)
+	if err != nil || !ok || !s.option.Config.TimestampVersions {
+		return m, ok, err
+	}
+	if err := checkTimestamp(strings.TrimPrefix(name, s.option.Config.StripPrefix)); err != nil {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+func (s *CodeUp) parseName(name string) (m *source.Migration, ok bool, err error) {
+	name = strings.TrimPrefix(name, s.option.Config.StripPrefix)
+	name = strings.TrimSuffix(name, gzipSuffix)
+	if s.option.Config.NormalizeNames {
+		name = normalizeName(name)
+	}
+	if s.option.Parser != nil {
+		m, err = s.option.Parser.Parse(name)
+		if err != nil || m == nil {
+			return nil, false, err
+		}
+		return m, true, nil
+	}
+	if len(s.option.Config.Extensions) != 0 {
+		return parseExtension(s.option.Config.Extensions, name)
+	}
+
+	m, err = source.Parse(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+// parseExtension parses a name of the form "{version}_{title}{suffix}",
+// where suffix is a key of extensions mapping to the direction.
+// The longest matching suffix is used; ok is false if none matches.
+func parseExtension(extensions map[string]source.Direction, name string) (m *source.Migration, ok bool, err error) {
+	var suffix string
+	for ext := range extensions {
+		if strings.HasSuffix(name, ext) && len(ext) > len(suffix) {
+			suffix = ext
+		}
+	}
+	if suffix == "" {
+		return nil, false, nil
+	}
+
+	match := versionPattern.FindStringSubmatch(strings.TrimSuffix(name, suffix))
+	if match == nil {
+		return nil, false, source.ErrParse
+	}
+	version, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &source.Migration{
+		Version:    uint(version),
+		Identifier: match[2],
+		Direction:  extensions[suffix],
+		Raw:        name,
+	}, true, nil
+}
+
+// parseVersionDir parses a version directory name of the form "{version}_{title}".
+func parseVersionDir(name string) (version uint, identifier string, ok bool) {
+	match := versionPattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, "", false
+	}
+	v, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return uint(v), match[2], true
+}
+
+// versionDirFile returns the direction of a file in a version directory,
+// which is named "up" or "down" with any extension, like "up.sql".
+func versionDirFile(name string) (source.Direction, bool) {
+	switch strings.TrimSuffix(name, path.Ext(name)) {
+	case "up":
+		return source.Up, true
+	case "down":
+		return source.Down, true
+	}
+	return "", false
+}
+
+// normalizeName lowercases the direction and extension of name,
+// like "1_Init.UP.sql" to "1_Init.up.sql".
+func normalizeName(name string) string {
+	lower := strings.ToLower(name)
+	i := strings.LastIndex(lower, ".up.")
+	if j := strings.LastIndex(lower, ".down."); j > i {
+		i = j
+	}
+	if i < 0 {
+		return name
+	}
+	return name[:i] + lower[i:]
+}
+
+// TimestampLayout is the layout of timestamp versions, like "20240101120000".
+const TimestampLayout = "20060102150405"
+
+// checkTimestamp checks that the version of name is a timestamp
+// in TimestampLayout that fits in uint.
+func checkTimestamp(name string) error {
+	match := versionPattern.FindStringSubmatch(name)
+	if match == nil {
+		return source.ErrParse
+	}
+	if _, err := strconv.ParseUint(match[1], 10, strconv.IntSize); err != nil {
+		return fmt.Errorf("version %s of %s does not fit in uint", match[1], name)
+	}
+	if _, err := time.Parse(TimestampLayout, match[1]); err != nil {
+		return fmt.Errorf("version %s of %s is not a timestamp: %w", match[1], name, err)
+	}
+	return nil
+}
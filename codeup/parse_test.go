@@ -0,0 +1,103 @@
+package codeup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestParse(t *testing.T) {
+	skipReadme := ParserFunc(func(name string) (*source.Migration, error) {
+		if !strings.HasSuffix(name, ".sql") {
+			return nil, nil
+		}
+		return source.Parse(name)
+	})
+	tests := []struct {
+		name    string
+		option  Option
+		file    string
+		want    uint
+		wantOk  bool
+		wantErr bool
+	}{
+		{"default", Option{}, "1_init.up.sql", 1, true, false},
+		{"unparseable", Option{}, "README.md", 0, false, true},
+		{"gzip", Option{}, "2_a.down.sql.gz", 2, true, false},
+		{"strip prefix", Option{Config: Config{StripPrefix: "m_"}}, "m_3_a.up.sql", 3, true, false},
+		{"normalize", Option{Config: Config{NormalizeNames: true}}, "4_A.UP.SQL", 4, true, false},
+		{"extension", Option{Config: Config{Extensions: map[string]source.Direction{".up.ddl": source.Up}}}, "5_a.up.ddl", 5, true, false},
+		{"other extension", Option{Config: Config{Extensions: map[string]source.Direction{".up.ddl": source.Up}}}, "5_a.up.sql", 0, false, false},
+		{"timestamp", Option{Config: Config{TimestampVersions: true}}, "20240101120000_a.up.sql", 20240101120000, true, false},
+		{"not a timestamp", Option{Config: Config{TimestampVersions: true}}, "20241301120000_a.up.sql", 0, false, true},
+		{"parser", Option{Parser: skipReadme}, "6_a.up.sql", 6, true, false},
+		{"parser skips", Option{Parser: skipReadme}, "README.md", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &CodeUp{option: tt.option}
+			m, ok, err := s.parse(tt.file)
+			if (err != nil) != tt.wantErr || ok != tt.wantOk {
+				t.Fatalf("parse(%q) = %v, %v, %v", tt.file, m, ok, err)
+			}
+			if ok && m.Version != tt.want {
+				t.Errorf("version = %d, want %d", m.Version, tt.want)
+			}
+		})
+	}
+}
+
+// TestParserNil checks that files a Parser returns no migration for are skipped.
+func TestParserNil(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"db/1_a.up.sql": "CREATE TABLE a;",
+		"db/README.md":  "docs",
+	})
+	option := testOption("db")
+	option.Parser = ParserFunc(func(name string) (*source.Migration, error) {
+		if name == "README.md" {
+			return nil, nil
+		}
+		return source.Parse(name)
+	})
+	d := newTestDriver(t, client, option)
+	if got := d.Versions(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Versions() = %v, want [1]", got)
+	}
+}
+
+func TestParseVersionDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint
+		id      string
+		ok      bool
+	}{
+		{"1_init", 1, "init", true},
+		{"20240101_a_b", 20240101, "a_b", true},
+		{"init", 0, "", false},
+		{"99999999999999999999999_a", 0, "", false},
+	}
+	for _, tt := range tests {
+		version, id, ok := parseVersionDir(tt.name)
+		if version != tt.version || id != tt.id || ok != tt.ok {
+			t.Errorf("parseVersionDir(%q) = %d, %q, %v", tt.name, version, id, ok)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"1_Init.UP.sql":    "1_Init.up.sql",
+		"1_Init.Down.SQL":  "1_Init.down.sql",
+		"1_Up.Down.sql":    "1_Up.down.sql",
+		"1_Init.sql":       "1_Init.sql",
+		"1_Init.up.sql.gz": "1_Init.up.sql.gz",
+	}
+	for name, want := range tests {
+		if got := normalizeName(name); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultPrefetchWorkers is the number of concurrent reads used by
+// prefetch when Option.PrefetchWorkers is not set.
+const DefaultPrefetchWorkers = 4
+
+// Prefetch reads the body of every migration that is not cached yet.
+// Bodies read before are kept, so after a failure it can be called
+// again to read only the missing ones.
+//
+// It needs Option.CacheContents or Option.Prefetch. With Option.PartialPrefetch,
+// Open does not fail when some bodies are not read; call Prefetch
+// to read them and see the error.
+func (s *CodeUp) Prefetch(ctx context.Context) error {
+	if s.cache == nil {
+		return errors.New("prefetch needs CacheContents")
+	}
+	return s.prefetch(ctx)
+}
+
+// prefetch reads the body of every migration into the cache.
+// Cached bodies are not read again.
+func (s *CodeUp) prefetch(ctx context.Context) error {
+	ms, err := s.loaded()
+	if err != nil {
+		return err
+	}
+
+	var raws []string
+	v, ok := ms.First()
+	for ok {
+		if m, found := ms.Up(v); found {
+			raws = append(raws, m.Raw)
+		}
+		if m, found := ms.Down(v); found {
+			raws = append(raws, m.Raw)
+		}
+		v, ok = ms.Next(v)
+	}
+
+	workers := s.option.PrefetchWorkers
+	if workers <= 0 {
+		workers = DefaultPrefetchWorkers
+	}
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	sem := make(chan struct{}, workers)
+	for _, raw := range raws {
+		raw := raw
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if _, err := s.read(ctx, raw); err != nil {
+				once.Do(func() { first = err })
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,94 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPrefetch(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	option := testOption("db")
+	option.Prefetch = true
+	d := newTestDriver(t, client, option)
+
+	if n := client.count("GetFileBlobs"); n != 5 {
+		t.Errorf("GetFileBlobs called %d times by Open, want 5", n)
+	}
+	client.reset()
+	for v := uint(1); v <= 3; v++ {
+		readUp(t, d, v)
+	}
+	readDown(t, d, 1)
+	if n := client.count("GetFileBlobs"); n != 0 {
+		t.Errorf("GetFileBlobs called %d times after prefetch, want 0", n)
+	}
+}
+
+func TestPrefetchError(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	client.blobErr = func(ref, filePath string) error {
+		if filePath == "db/2_users.up.sql" {
+			return sdkError("ServiceUnavailable", 503, "try again")
+		}
+		return nil
+	}
+	option := testOption("db")
+	option.Prefetch = true
+	if _, err := WithInstance(client, option); !errors.Is(err, ErrAPIFailure) {
+		t.Errorf("WithInstance = %v, want the prefetch error", err)
+	}
+}
+
+func TestPrefetchNeedsCache(t *testing.T) {
+	d := newTestDriver(t, newFakeClient(sampleFiles), testOption("db"))
+	if err := d.Prefetch(context.Background()); err == nil {
+		t.Error("Prefetch without a cache succeeded")
+	}
+}
+
+// TestPrefetchResume checks that a failed prefetch keeps the bodies read
+// and that Reload reads only the others.
+func TestPrefetchResume(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	failed := false
+	client.blobErr = func(ref, filePath string) error {
+		if filePath == "db/2_users.up.sql" && !failed {
+			failed = true
+			return sdkError("ServiceUnavailable", 503, "try again")
+		}
+		return nil
+	}
+	var log eventLog
+	option := testOption("db")
+	option.Prefetch = true
+	option.PartialPrefetch = true
+	option.PrefetchWorkers = 1
+	option.Logger = &log
+	d := newTestDriver(t, client, option)
+
+	if events := log.ops(OpRead); len(events) != 5 || countErrors(events) != 1 {
+		t.Errorf("Open logged %d reads with %d errors, want 5 with 1", len(events), countErrors(events))
+	}
+	client.reset()
+	if err := d.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	calls := client.received("GetFileBlobs")
+	if len(calls) != 1 || calls[0].path != "db/2_users.up.sql" {
+		t.Errorf("Reload read %v, want only db/2_users.up.sql", calls)
+	}
+	if got := readUp(t, d, 2); got != "CREATE TABLE users;" {
+		t.Errorf("ReadUp(2) = %q", got)
+	}
+}
+
+func countErrors(events []Event) int {
+	n := 0
+	for _, e := range events {
+		if e.Err != nil {
+			n++
+		}
+	}
+	return n
+}
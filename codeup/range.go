@@ -0,0 +1,108 @@
+package codeup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Body is the content of a migration read by ReadUpRange.
+type Body struct {
+	Version    uint
+	Identifier string
+	Content    []byte
+}
+
+// RangeError lists the versions that ReadUpRange failed to read.
+type RangeError struct {
+	Errors map[uint]error
+}
+
+func (e *RangeError) Error() string {
+	versions := make([]int, 0, len(e.Errors))
+	for v := range e.Errors {
+		versions = append(versions, int(v))
+	}
+	sort.Ints(versions)
+
+	msgs := make([]string, len(versions))
+	for i, v := range versions {
+		msgs[i] = fmt.Sprintf("version %d: %v", v, e.Errors[uint(v)])
+	}
+	return "read up migrations: " + strings.Join(msgs, "; ")
+}
+
+// ReadUpRange returns the up migrations of the versions from from to to,
+// both included, in ascending order. Bodies are read concurrently by
+// Option.PrefetchWorkers workers.
+//
+// If any read fails, the error is a *RangeError naming every failed version.
+func (s *CodeUp) ReadUpRange(from, to uint) ([]Body, error) {
+	ms, err := s.loaded()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []uint
+	v, ok := ms.First()
+	for ok && v <= to {
+		if _, up := ms.Up(v); up && v >= from {
+			versions = append(versions, v)
+		}
+		v, ok = ms.Next(v)
+	}
+
+	workers := s.option.PrefetchWorkers
+	if workers <= 0 {
+		workers = DefaultPrefetchWorkers
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[uint]error)
+	)
+	bodies := make([]Body, len(versions))
+	sem := make(chan struct{}, workers)
+	for i, v := range versions {
+		i, v := i, v
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			body, err := s.readUpBody(v)
+			if err != nil {
+				mu.Lock()
+				errs[v] = err
+				mu.Unlock()
+				return
+			}
+			bodies[i] = body
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, &RangeError{Errors: errs}
+	}
+	return bodies, nil
+}
+
+func (s *CodeUp) readUpBody(version uint) (Body, error) {
+	r, identifier, err := s.ReadUp(version)
+	if err != nil {
+		return Body{}, err
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return Body{}, err
+	}
+	return Body{Version: version, Identifier: identifier, Content: content}, nil
+}
@@ -0,0 +1,70 @@
+package codeup
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadUpRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to uint
+		want     []uint
+	}{
+		{"all", 0, 10, []uint{1, 2, 3}},
+		{"middle", 2, 2, []uint{2}},
+		{"from a gap", 2, 3, []uint{2, 3}},
+		{"none", 4, 9, nil},
+	}
+	option := testOption("db")
+	option.PrefetchWorkers = 2
+	d := newTestDriver(t, newFakeClient(sampleFiles), option)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies, err := d.ReadUpRange(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, b := range bodies {
+				got = append(got, b.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	bodies, _ := d.ReadUpRange(2, 2)
+	if want := (Body{Version: 2, Identifier: "users", Content: []byte("CREATE TABLE users;")}); !reflect.DeepEqual(bodies[0], want) {
+		t.Errorf("body = %+v, want %+v", bodies[0], want)
+	}
+}
+
+func TestReadUpRangeErrors(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	d := newTestDriver(t, client, testOption("db"))
+	client.remove("master", "db/1_init.up.sql")
+	client.remove("master", "db/3_index.up.sql")
+
+	_, err := d.ReadUpRange(1, 3)
+	var rerr *RangeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("err = %v, want a *RangeError", err)
+	}
+	if len(rerr.Errors) != 2 || !errors.Is(rerr.Errors[1], ErrFileNotFound) || !errors.Is(rerr.Errors[3], ErrFileNotFound) {
+		t.Errorf("errors = %v, want versions 1 and 3 not found", rerr.Errors)
+	}
+	if !strings.HasPrefix(err.Error(), "read up migrations: version 1: ") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestRangeErrorOrder(t *testing.T) {
+	err := &RangeError{Errors: map[uint]error{10: errors.New("b"), 2: errors.New("a")}}
+	if got, want := err.Error(), "read up migrations: version 2: a; version 10: b"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package codeup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket rate limiter.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second.
+	burst  float64 // bucket size.
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func newLimiter(rate float64, burst int, clock Clock) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+		clock:  clock,
+	}
+}
+
+// wait blocks until a token is available or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.cancel()
+		return context.DeadlineExceeded
+	}
+
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-l.clock.After(delay):
+		return nil
+	}
+}
+
+// cancel returns a token taken by a wait that gave up.
+func (l *limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
@@ -0,0 +1,105 @@
+package codeup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(2, 2, clock)
+	for i := 0; i < 2; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(clock.waited()); n != 0 {
+		t.Fatalf("burst calls waited %d times", n)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.wait(context.Background()) }()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := clock.waited(), []time.Duration{500 * time.Millisecond}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+
+	// The bucket refills up to the burst only.
+	clock.advance(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		l.wait(context.Background())
+	}
+	if n := len(clock.waited()); n != 1 {
+		t.Errorf("calls after a refill waited %d times, want none", n-1)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(1, 0, clock)
+	l.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait past the deadline = %v, want context.DeadlineExceeded", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		for clock.pending() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("canceled wait = %v, want context.Canceled", err)
+	}
+
+	// The canceled waits gave their tokens back.
+	clock.advance(time.Second)
+	waits := len(clock.waited())
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(clock.waited()) - waits; n != 0 {
+		t.Errorf("wait after a refill waited %d times, want none", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	option := testOption("db")
+	option.Clock = clock
+	option.RateLimit = 1
+	option.RateBurst = 1
+	d := newTestDriver(t, newFakeClient(sampleFiles), option)
+
+	done := make(chan string)
+	go func() {
+		r, _, err := d.ReadUp(1)
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		r.Close()
+		done <- ""
+	}()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.advance(time.Second)
+	if err := <-done; err != "" {
+		t.Fatal(err)
+	}
+	if got := clock.waited(); !reflect.DeepEqual(got, []time.Duration{time.Second}) {
+		t.Errorf("waited %v, want the read to wait a second after the listing", got)
+	}
+}
@@ -0,0 +1,34 @@
+package codeup
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// bodyReader is a migration body returned by the driver.
+// It counts as in flight until it is closed; Close may be called more than once.
+type bodyReader struct {
+	io.Reader
+	s    *CodeUp
+	once sync.Once
+}
+
+// newBodyReader returns r as an in-flight body of s.
+func (s *CodeUp) newBodyReader(r io.Reader) io.ReadCloser {
+	atomic.AddInt64(&s.inFlight, 1)
+	return &bodyReader{Reader: r, s: s}
+}
+
+func (b *bodyReader) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.s.inFlight, -1)
+	})
+	return nil
+}
+
+// InFlight returns the number of migration bodies returned by the driver
+// that are not closed yet.
+func (s *CodeUp) InFlight() int {
+	return int(atomic.LoadInt64(&s.inFlight))
+}
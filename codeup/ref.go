@@ -0,0 +1,150 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	devops "github.com/alibabacloud-go/devops-20210625/v4/client"
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// Kinds of Config.Ref.
+const (
+	RefBranch = "branch"
+	RefTag    = "tag"
+	RefCommit = "commit"
+)
+
+// refResolver is implemented by clients that can look up refs.
+type refResolver interface {
+	GetBranchInfoWithOptions(repositoryId *string, request *devops.GetBranchInfoRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetBranchInfoResponse, error)
+	GetRepositoryTagWithOptions(repositoryId *string, request *devops.GetRepositoryTagRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryTagResponse, error)
+	GetRepositoryCommitWithOptions(repositoryId *string, sha *string, request *devops.GetRepositoryCommitRequest, headers map[string]*string, runtime *service.RuntimeOptions) (*devops.GetRepositoryCommitResponse, error)
+}
+
+// checkRef returns ErrRefNotFound if the ref listed, Config.ReadRef
+// or any of Config.Refs names no branch, tag or commit.
+// Config.Ref is only checked if Config.ListRef does not replace it,
+// and is looked up as Config.RefKind if that is set.
+// It does nothing if the client can not look up refs.
+func (s *CodeUp) checkRef(ctx context.Context) error {
+	r, ok := s.client.(refResolver)
+	if !ok {
+		return nil
+	}
+	c := s.option.Config
+	kind := c.RefKind
+	if c.ListRef != "" {
+		kind = ""
+	}
+	if err := s.lookupRef(ctx, r, c.listRef(), kind); err != nil {
+		return err
+	}
+	for _, ref := range append([]string{c.ReadRef}, c.Refs...) {
+		if ref == "" {
+			continue
+		}
+		if err := s.lookupRef(ctx, r, ref, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupRef returns ErrRefNotFound if ref names nothing of kind,
+// or no branch, tag or commit if kind is "".
+func (s *CodeUp) lookupRef(ctx context.Context, r refResolver, ref, kind string) error {
+	var lookups []func(context.Context, refResolver, string) error
+	switch kind {
+	case RefBranch:
+		lookups = append(lookups, s.getBranch)
+	case RefTag:
+		lookups = append(lookups, s.getTag)
+	case RefCommit:
+		lookups = append(lookups, s.getCommit)
+	default:
+		if isCommitId(ref) {
+			lookups = append(lookups, s.getCommit)
+		}
+		lookups = append(lookups, s.getBranch, s.getTag)
+	}
+	for _, lookup := range lookups {
+		err := lookup(ctx, r, ref)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, ErrRefNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+}
+
+func (s *CodeUp) getBranch(ctx context.Context, r refResolver, name string) error {
+	resp, err := callAPI(ctx, s, "GetBranchInfo", func() (*devops.GetBranchInfoResponse, error) {
+		return r.GetBranchInfoWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			&devops.GetBranchInfoRequest{
+				OrganizationId: tea.String(s.option.Config.OrganizationId),
+				AccessToken:    tea.String(s.option.Config.AccessToken),
+				BranchName:     tea.String(name),
+			},
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return callError(err)
+	}
+	if body := resp.Body; !tea.BoolValue(body.Success) {
+		return responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+	}
+	return nil
+}
+
+func (s *CodeUp) getTag(ctx context.Context, r refResolver, name string) error {
+	resp, err := callAPI(ctx, s, "GetRepositoryTag", func() (*devops.GetRepositoryTagResponse, error) {
+		return r.GetRepositoryTagWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			&devops.GetRepositoryTagRequest{
+				OrganizationId: tea.String(s.option.Config.OrganizationId),
+				AccessToken:    tea.String(s.option.Config.AccessToken),
+				TagName:        tea.String(name),
+			},
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return callError(err)
+	}
+	if body := resp.Body; !tea.BoolValue(body.Success) {
+		return responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+	}
+	return nil
+}
+
+func (s *CodeUp) getCommit(ctx context.Context, r refResolver, sha string) error {
+	resp, err := callAPI(ctx, s, "GetRepositoryCommit", func() (*devops.GetRepositoryCommitResponse, error) {
+		return r.GetRepositoryCommitWithOptions(
+			tea.String(s.option.Config.ProjectId),
+			tea.String(sha),
+			&devops.GetRepositoryCommitRequest{
+				OrganizationId: tea.String(s.option.Config.OrganizationId),
+				AccessToken:    tea.String(s.option.Config.AccessToken),
+			},
+			s.headers(),
+			s.option.Runtime,
+		)
+	})
+	if err != nil {
+		return callError(err)
+	}
+	if body := resp.Body; !tea.BoolValue(body.Success) {
+		return responseError(body.ErrorCode, body.ErrorMessage, body.RequestId)
+	}
+	return nil
+}
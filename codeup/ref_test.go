@@ -0,0 +1,82 @@
+package codeup
+
+import (
+	"errors"
+	iurl "net/url"
+	"testing"
+)
+
+func TestCheckRef(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name      string
+		ref, kind string
+		skip      bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{"branch", "master", "", false, 1, false},
+		{"tag", "v1", "", false, 2, false},
+		{"commit", commit, "", false, 1, false},
+		{"kind", "v1", RefTag, false, 1, false},
+		{"wrong kind", "v1", RefBranch, false, 1, true},
+		{"missing", "gone", "", false, 2, true},
+		{"skipped", "master", "", true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeClient(nil)
+			for _, ref := range []string{"master", "v1", commit} {
+				fake.set(ref, "db/1_a.up.sql", "CREATE TABLE a;")
+			}
+			fake.tags = map[string]bool{"v1": true}
+			client := refFake{fake}
+
+			option := testOption("db")
+			option.Config.Ref = tt.ref
+			option.Config.RefKind = tt.kind
+			option.SkipRefCheck = tt.skip
+			lookups := func() int {
+				return fake.count("GetBranchInfo") + fake.count("GetRepositoryTag") + fake.count("GetRepositoryCommit")
+			}
+
+			d, err := WithInstance(client, option)
+			if tt.wantErr {
+				if !errors.Is(err, ErrRefNotFound) {
+					t.Errorf("err = %v, want ErrRefNotFound", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				d.Close()
+			}
+			if n := lookups(); n != tt.wantCalls {
+				t.Errorf("%d ref lookups, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSkipRefCheckUrl(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"&skipRefCheck=true", true, false},
+		{"&skipRefCheck=false", false, false},
+		{"&skipRefCheck=maybe", false, true},
+	}
+	for _, tt := range tests {
+		u, _ := iurl.Parse("codeup://org/project/db?ref=master" + tt.query)
+		option, err := optionFromUrl(u)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v", tt.query, err)
+			continue
+		}
+		if option.SkipRefCheck != tt.want {
+			t.Errorf("%q: SkipRefCheck = %v, want %v", tt.query, option.SkipRefCheck, tt.want)
+		}
+	}
+}
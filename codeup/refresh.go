@@ -0,0 +1,23 @@
+package codeup
+
+import "time"
+
+// refresh reloads s, waiting interval between reloads,
+// until s is closed or its context is done.
+func (s *CodeUp) refresh(interval time.Duration) {
+	var done <-chan struct{}
+	if s.ctx != nil {
+		done = s.ctx.Done()
+	}
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-done:
+			return
+		case <-s.clock().After(interval):
+			err := s.Reload(s.ctx)
+			s.log(Event{Op: OpReload, Path: s.option.Config.Path, Ref: s.option.Config.Ref, Err: err})
+		}
+	}
+}
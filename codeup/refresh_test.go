@@ -0,0 +1,89 @@
+package codeup
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshInterval(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	clock := newFakeClock()
+	var log eventLog
+	option := testOption("db")
+	option.Clock = clock
+	option.Logger = &log
+	option.RefreshInterval = time.Minute
+	d := newTestDriver(t, client, option)
+
+	client.set("master", "db/4_more.up.sql", "SELECT 4;")
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.advance(time.Minute)
+	for len(log.ops(OpReload)) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if e := log.ops(OpReload)[0]; e.Err != nil || e.Path != "db" || e.Ref != "master" {
+		t.Errorf("reload event = %+v", e)
+	}
+	if !d.Has(4) {
+		t.Error("refresh did not load the added version")
+	}
+
+	// Close stops the refresh.
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	d.Close()
+	time.Sleep(10 * time.Millisecond)
+	clock.advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if n := len(log.ops(OpReload)); n != 1 {
+		t.Errorf("%d reloads after Close, want none", n-1)
+	}
+}
+
+// TestRefreshClose checks that Close waits for a reload in progress.
+func TestRefreshClose(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	clock := newFakeClock()
+	option := testOption("db")
+	option.Clock = clock
+	option.RefreshInterval = time.Minute
+	option.CacheContents = true
+	d := newTestDriver(t, client, option)
+
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	client.treeErr = func(ref, dir string) error {
+		close(listing)
+		<-release
+		return nil
+	}
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.advance(time.Minute)
+	<-listing
+
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned during a reload")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the reload")
+	}
+	if _, err := d.First(); !errors.Is(err, ErrClosed) {
+		t.Errorf("First() after Close: err = %v, want ErrClosed", err)
+	}
+}
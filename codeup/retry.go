@@ -0,0 +1,43 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultRefRetryDelay is the delay between reads retried by Option.RefRetries
+// when Option.RefRetryDelay is not set.
+const DefaultRefRetryDelay = 500 * time.Millisecond
+
+// fetchRetry is fetchRef retried up to Option.RefRetries times
+// while ref is not found, which happens briefly when a branch is force-pushed.
+// Other errors are returned at once.
+func (s *CodeUp) fetchRetry(ctx context.Context, filePath, ref string) (string, error) {
+	delay := s.option.RefRetryDelay
+	if delay <= 0 {
+		delay = DefaultRefRetryDelay
+	}
+
+	content, err := s.fetchRef(ctx, filePath, ref)
+	for i := 0; i < s.option.RefRetries && errors.Is(err, ErrRefNotFound); i++ {
+		if err := sleep(ctx, s.clock(), delay); err != nil {
+			return "", err
+		}
+		content, err = s.fetchRef(ctx, filePath, ref)
+	}
+	return content, err
+}
+
+// sleep waits for d on clock, or returns ctx.Err() if ctx is done first.
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		fails     int
+		wantReads int
+		wantErr   bool
+	}{
+		{"no retries", 0, 1, 1, true},
+		{"recovers", 2, 2, 3, false},
+		{"gives up", 2, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(sampleFiles)
+			fails := 0
+			client.blobErr = func(ref, filePath string) error {
+				if fails < tt.fails {
+					fails++
+					return sdkError("NotFound", 404, "branch master not found")
+				}
+				return nil
+			}
+			clock := newFakeClock()
+			option := testOption("db")
+			option.Clock = clock
+			option.RefRetries = tt.retries
+			option.RefRetryDelay = time.Second
+			d := newTestDriver(t, client, option)
+
+			done := make(chan error)
+			go func() {
+				_, err := d.fetchRetry(context.Background(), "db/1_init.up.sql", "master")
+				done <- err
+			}()
+			var err error
+		wait:
+			for {
+				select {
+				case err = <-done:
+					break wait
+				default:
+					if clock.pending() > 0 {
+						clock.advance(time.Second)
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrRefNotFound) {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if n := client.count("GetFileBlobs"); n != tt.wantReads {
+				t.Errorf("%d reads, want %d", n, tt.wantReads)
+			}
+		})
+	}
+}
+
+func TestRefRetriesOther(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	option := testOption("db")
+	option.RefRetries = 3
+	d := newTestDriver(t, client, option)
+	if _, err := d.fetchRetry(context.Background(), "db/none.sql", "master"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("err = %v, want ErrFileNotFound", err)
+	}
+	if n := client.count("GetFileBlobs"); n != 1 {
+		t.Errorf("missing file read %d times, want once", n)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleep(ctx, newFakeClock(), time.Hour); err != context.Canceled {
+		t.Errorf("sleep with a done context = %v, want context.Canceled", err)
+	}
+	if err := sleep(nil, newFakeClock(), 0); err != nil {
+		t.Errorf("sleep(0) = %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package codeup
+
+// Stats summarizes the loaded migrations.
+//
+// Tree entries carry no file size, so no byte size is reported.
+type Stats struct {
+	Versions int // number of versions.
+	Up       int // number of up migrations.
+	Down     int // number of down migrations.
+}
+
+// Stats returns the summary of the loaded migrations, without any API call.
+func (s *CodeUp) Stats() Stats {
+	ms, err := s.loaded()
+	if err != nil {
+		return Stats{}
+	}
+
+	var st Stats
+	v, ok := ms.First()
+	for ok {
+		st.Versions++
+		if _, found := ms.Up(v); found {
+			st.Up++
+		}
+		if _, found := ms.Down(v); found {
+			st.Down++
+		}
+		v, ok = ms.Next(v)
+	}
+	return st
+}
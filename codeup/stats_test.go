@@ -0,0 +1,19 @@
+package codeup
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	d := newTestDriver(t, client, testOption("db"))
+	if got, want := d.Stats(), (Stats{Versions: 3, Up: 3, Down: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if n := client.count("GetFileBlobs"); n != 0 {
+		t.Errorf("Stats read %d files", n)
+	}
+
+	d.Close()
+	if got := d.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() after Close = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,25 @@
+package codeup
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// readContext returns ctx limited by Option.ReadTimeout, if set.
+//
+// A random jitter of up to a tenth of the timeout is added,
+// so reads started together do not all time out and retry together.
+func (s *CodeUp) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := s.option.ReadTimeout
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if jitter := int64(timeout / 10); jitter > 0 {
+		timeout += time.Duration(rand.Int63n(jitter))
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,76 @@
+package codeup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadTimeout(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	client.delay = 200 * time.Millisecond
+	option := testOption("db")
+	option.ReadTimeout = 20 * time.Millisecond
+	d := newTestDriver(t, client, option)
+
+	_, _, err := d.ReadUp(1)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if errors.Is(err, ErrFileNotFound) {
+		t.Errorf("timed out read matches ErrFileNotFound: %v", err)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	d := &CodeUp{}
+	ctx, cancel := d.readContext(nil)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("read without ReadTimeout has a deadline")
+	}
+
+	d.option.ReadTimeout = time.Minute
+	start := time.Now()
+	ctx, cancel = d.readContext(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("read with ReadTimeout has no deadline")
+	}
+	if left := deadline.Sub(start); left < time.Minute || left > time.Minute+6*time.Second+time.Second {
+		t.Errorf("deadline in %v, want a minute plus at most a tenth", left)
+	}
+}
+
+// TestReadCtxCancel checks that ReadUpCtx and ReadDownCtx return
+// when their context is cancelled during a read.
+func TestReadCtxCancel(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	client.delay = 200 * time.Millisecond
+	d := newTestDriver(t, client, testOption("db"))
+
+	reads := map[string]func(context.Context) error{
+		"ReadUpCtx": func(ctx context.Context) error {
+			_, _, err := d.ReadUpCtx(ctx, 1)
+			return err
+		},
+		"ReadDownCtx": func(ctx context.Context) error {
+			_, _, err := d.ReadDownCtx(ctx, 1)
+			return err
+		},
+	}
+	for name, read := range reads {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		start := time.Now()
+		err := read(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: err = %v, want context.Canceled", name, err)
+		}
+		if elapsed := time.Since(start); elapsed >= client.delay {
+			t.Errorf("%s returned after %v, want before the read finished", name, elapsed)
+		}
+	}
+}
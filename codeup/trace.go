@@ -0,0 +1,67 @@
+package codeup
+
+import (
+	"context"
+	"strconv"
+)
+
+// Tracer starts a span around each ListRepositoryTree and GetFileBlobs call,
+// like an adapter to an OpenTelemetry trace.Tracer.
+type Tracer interface {
+	// Start starts the span of the API call name with attrs, like
+	// "codeup.project", "codeup.path", "codeup.ref" and, when reading
+	// a migration, "codeup.version". The returned context is used for the call.
+	Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span, recording err if the call failed.
+	End(err error)
+}
+
+// TracerFunc adapts a span-starting function to Tracer.
+type TracerFunc func(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+
+// Start calls f(ctx, name, attrs).
+func (f TracerFunc) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	return f(ctx, name, attrs)
+}
+
+// versionKey is the context key of the version a read is for.
+type versionKey struct{}
+
+// withVersion returns ctx noting that its reads are for version,
+// for their spans. It is ctx if Option.Tracer is not set.
+func (s *CodeUp) withVersion(ctx context.Context, version uint) context.Context {
+	if s.option.Tracer == nil {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// trace starts the span of the API call name on filePath at ref
+// if Option.Tracer is set. The returned function ends it.
+func (s *CodeUp) trace(ctx context.Context, name, filePath, ref string) (context.Context, func(error)) {
+	if s.option.Tracer == nil {
+		return ctx, func(error) {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	attrs := map[string]string{
+		"codeup.organization": s.option.Config.OrganizationId,
+		"codeup.project":      s.option.Config.ProjectId,
+		"codeup.path":         filePath,
+		"codeup.ref":          ref,
+	}
+	if v, ok := ctx.Value(versionKey{}).(uint); ok {
+		attrs["codeup.version"] = strconv.FormatUint(uint64(v), 10)
+	}
+	ctx, span := s.option.Tracer.Start(ctx, name, attrs)
+	return ctx, span.End
+}
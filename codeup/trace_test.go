@@ -0,0 +1,91 @@
+package codeup
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// span is a span recorded by a spanLog.
+type span struct {
+	name  string
+	attrs map[string]string
+	ended bool
+	err   error
+}
+
+// spanLog is a Tracer recording the spans it starts.
+type spanLog struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (l *spanLog) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := &span{name: name, attrs: attrs}
+	l.spans = append(l.spans, s)
+	return ctx, spanEnd{l, s}
+}
+
+type spanEnd struct {
+	l *spanLog
+	s *span
+}
+
+func (e spanEnd) End(err error) {
+	e.l.mu.Lock()
+	defer e.l.mu.Unlock()
+	e.s.ended, e.s.err = true, err
+}
+
+func TestTracer(t *testing.T) {
+	client := newFakeClient(sampleFiles)
+	tracer := &spanLog{}
+	option := testOption("db")
+	option.Tracer = tracer
+	d := newTestDriver(t, client, option)
+
+	readUp(t, d, 2)
+	client.remove("master", "db/1_init.up.sql")
+	d.ReadUp(1)
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("%d spans, want 3", len(tracer.spans))
+	}
+	list, read, failed := tracer.spans[0], tracer.spans[1], tracer.spans[2]
+	if list.name != "ListRepositoryTree" || list.attrs["codeup.path"] != "db" || list.attrs["codeup.version"] != "" {
+		t.Errorf("list span = %+v", list)
+	}
+	want := map[string]string{
+		"codeup.organization": "org",
+		"codeup.project":      "project",
+		"codeup.path":         "db/2_users.up.sql",
+		"codeup.ref":          "master",
+		"codeup.version":      "2",
+	}
+	if read.name != "GetFileBlobs" || !reflect.DeepEqual(read.attrs, want) {
+		t.Errorf("read span = %+v, want attrs %v", read, want)
+	}
+	for _, s := range tracer.spans {
+		if !s.ended {
+			t.Errorf("span %s was not ended", s.name)
+		}
+	}
+	if read.err != nil || failed.err == nil {
+		t.Errorf("span errors = %v, %v; want only the second read failed", read.err, failed.err)
+	}
+}
+
+func TestTracerFunc(t *testing.T) {
+	var got string
+	f := TracerFunc(func(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+		got = name
+		return ctx, nil
+	})
+	f.Start(context.Background(), "call", nil)
+	if got != "call" {
+		t.Errorf("TracerFunc started %q, want call", got)
+	}
+}
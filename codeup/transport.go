@@ -0,0 +1,174 @@
+package codeup
+
+import (
+	"fmt"
+	"net"
+	iurl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// Transport is the HTTP transport setting of the devops client.
+//
+// The devops client builds its own http.Client, so only the settings
+// it supports can be changed. Zero values keep the client defaults.
+// Open also takes the HTTPS proxy from the standard environment
+// variables if the URL sets none.
+type Transport struct {
+	HttpProxy   string
+	HttpsProxy  string
+	NoProxy     string // comma separated hosts.
+	Socks5Proxy string
+
+	CA        string // PEM encoded certificate authorities.
+	Cert      string // PEM encoded client certificate.
+	Key       string // PEM encoded client key.
+	IgnoreSSL bool
+
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	MaxIdleConns   int
+}
+
+// apply sets the non-zero settings of t on r.
+func (t *Transport) apply(r *service.RuntimeOptions) {
+	if t.HttpProxy != "" {
+		r.HttpProxy = tea.String(t.HttpProxy)
+	}
+	if t.HttpsProxy != "" {
+		r.HttpsProxy = tea.String(t.HttpsProxy)
+	}
+	if t.NoProxy != "" {
+		r.NoProxy = tea.String(t.NoProxy)
+	}
+	if t.Socks5Proxy != "" {
+		r.Socks5Proxy = tea.String(t.Socks5Proxy)
+	}
+	if t.CA != "" {
+		r.Ca = tea.String(t.CA)
+	}
+	if t.Cert != "" {
+		r.Cert = tea.String(t.Cert)
+	}
+	if t.Key != "" {
+		r.Key = tea.String(t.Key)
+	}
+	if t.IgnoreSSL {
+		r.IgnoreSSL = tea.Bool(true)
+	}
+	if t.ConnectTimeout > 0 {
+		r.ConnectTimeout = tea.Int(int(t.ConnectTimeout / time.Millisecond))
+	}
+	if t.ReadTimeout > 0 {
+		r.ReadTimeout = tea.Int(int(t.ReadTimeout / time.Millisecond))
+	}
+	if t.MaxIdleConns > 0 {
+		r.MaxIdleConns = tea.Int(t.MaxIdleConns)
+	}
+}
+
+// proxyFromEnvironment sets the HTTPS proxy of t, if neither it nor the
+// SOCKS5 proxy is set, from HTTPS_PROXY, or else ALL_PROXY or HTTP_PROXY,
+// unless NO_PROXY matches host.
+//
+// The devops client reads HTTPS_PROXY and NO_PROXY itself, but matches
+// NO_PROXY against whole hosts only and ignores the others for its HTTPS
+// requests. SOCKS5 proxies are not taken from the environment.
+func (t *Transport) proxyFromEnvironment(host string) {
+	if t.HttpsProxy != "" || t.Socks5Proxy != "" {
+		return
+	}
+	if noProxy(getenv("NO_PROXY"), host) {
+		// Keep the devops client from using HTTPS_PROXY too.
+		if t.NoProxy != "" {
+			t.NoProxy += ","
+		}
+		t.NoProxy += host
+		return
+	}
+	for _, name := range []string{"HTTPS_PROXY", "ALL_PROXY", "HTTP_PROXY"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(v), "socks") {
+			return
+		}
+		t.HttpsProxy = v
+		return
+	}
+}
+
+// getenv returns the environment variable name, or its lower case form.
+func getenv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// noProxy reports whether the NO_PROXY list value matches host:
+// "*", host itself, or a domain of host, with or without a leading dot.
+func noProxy(value, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		switch {
+		case entry == "":
+		case entry == "*", entry == host, strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+	return false
+}
+
+func transportFromUrl(u *iurl.URL) (*Transport, error) {
+	query := u.Query()
+	t := &Transport{
+		HttpProxy:   query.Get("httpProxy"),
+		HttpsProxy:  query.Get("httpsProxy"),
+		NoProxy:     query.Get("noProxy"),
+		Socks5Proxy: query.Get("socks5Proxy"),
+	}
+	if v := query.Get("ignoreSSL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		t.IgnoreSSL = b
+	}
+	for name, d := range map[string]*time.Duration{
+		"connectTimeout": &t.ConnectTimeout,
+		"readTimeout":    &t.ReadTimeout,
+	} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, v)
+		}
+		*d = timeout
+	}
+	if v := query.Get("maxIdleConns"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		t.MaxIdleConns = n
+	}
+	return t, nil
+}
@@ -0,0 +1,148 @@
+package codeup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestTransportFromUrl(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Transport
+		wantErr bool
+	}{
+		{"", Transport{}, false},
+		{
+			"httpsProxy=http://proxy:3128&noProxy=a,b&ignoreSSL=true",
+			Transport{HttpsProxy: "http://proxy:3128", NoProxy: "a,b", IgnoreSSL: true},
+			false,
+		},
+		{
+			"connectTimeout=2s&readTimeout=1m&maxIdleConns=8",
+			Transport{ConnectTimeout: 2 * time.Second, ReadTimeout: time.Minute, MaxIdleConns: 8},
+			false,
+		},
+		{"socks5Proxy=socks5://proxy:1080", Transport{Socks5Proxy: "socks5://proxy:1080"}, false},
+		{"readTimeout=-1s", Transport{}, true},
+		{"connectTimeout=soon", Transport{}, true},
+		{"ignoreSSL=maybe", Transport{}, true},
+		{"maxIdleConns=many", Transport{}, true},
+	}
+	for _, tt := range tests {
+		got, err := transportFromUrl(mustParseUrl(t, "codeup://host/db?"+tt.query))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v", tt.query, err)
+			continue
+		}
+		if err == nil && *got != tt.want {
+			t.Errorf("%q: Transport = %+v, want %+v", tt.query, *got, tt.want)
+		}
+	}
+}
+
+func TestTransportApply(t *testing.T) {
+	r := &service.RuntimeOptions{Autoretry: tea.Bool(true), ReadTimeout: tea.Int(5)}
+	(&Transport{HttpsProxy: "http://proxy", ConnectTimeout: 1500 * time.Millisecond}).apply(r)
+	want := &service.RuntimeOptions{
+		Autoretry:      tea.Bool(true),
+		ReadTimeout:    tea.Int(5),
+		HttpsProxy:     tea.String("http://proxy"),
+		ConnectTimeout: tea.Int(1500),
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("apply = %+v, want %+v", r, want)
+	}
+}
+
+func TestTransportRuntime(t *testing.T) {
+	option := testOption("db")
+	option.Runtime = &service.RuntimeOptions{MaxAttempts: tea.Int(3)}
+	option.Transport = &Transport{ReadTimeout: 2 * time.Second}
+	runtime := option.Runtime
+	d := newTestDriver(t, newFakeClient(sampleFiles), option)
+
+	if got := tea.IntValue(d.option.Runtime.ReadTimeout); got != 2000 {
+		t.Errorf("ReadTimeout = %d, want 2000", got)
+	}
+	if got := tea.IntValue(d.option.Runtime.MaxAttempts); got != 3 {
+		t.Errorf("MaxAttempts = %d, want 3", got)
+	}
+	if runtime.ReadTimeout != nil {
+		t.Error("the Runtime of the caller was changed")
+	}
+}
+
+// TestTransportProxy checks that the devops client sends its calls
+// through the proxy set in Transport.
+func TestTransportProxy(t *testing.T) {
+	proxy, paths := newTestServer(t, sampleFiles)
+	option := testOption("db")
+	option.Transport = &Transport{HttpProxy: proxy.URL}
+	option.SkipRefCheck = true
+	d := newTestDriver(t, newServerClient(t, "codeup.invalid"), option)
+
+	if got := readUp(t, d, 1); got != "CREATE TABLE a;" {
+		t.Errorf("ReadUp(1) = %q", got)
+	}
+	if got := paths(); len(got) != 2 {
+		t.Errorf("proxy received %v, want a listing and a read", got)
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	tests := []struct {
+		value, host string
+		want        bool
+	}{
+		{"", "devops.aliyuncs.com", false},
+		{"*", "devops.aliyuncs.com", true},
+		{"devops.aliyuncs.com", "devops.aliyuncs.com:443", true},
+		{".aliyuncs.com", "devops.aliyuncs.com", true},
+		{"aliyuncs.com", "devops.aliyuncs.com", true},
+		{"example.com, aliyuncs.com:443", "devops.aliyuncs.com", true},
+		{"cs.com", "devops.aliyuncs.com", false},
+		{"other.com", "devops.aliyuncs.com", false},
+	}
+	for _, tt := range tests {
+		if got := noProxy(tt.value, tt.host); got != tt.want {
+			t.Errorf("noProxy(%q, %q) = %v, want %v", tt.value, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		t    Transport
+		want Transport
+	}{
+		{"none", nil, Transport{}, Transport{}},
+		{"https", map[string]string{"HTTPS_PROXY": "http://a"}, Transport{}, Transport{HttpsProxy: "http://a"}},
+		{"lower case", map[string]string{"https_proxy": "http://a"}, Transport{}, Transport{HttpsProxy: "http://a"}},
+		{"all", map[string]string{"ALL_PROXY": "http://b", "HTTP_PROXY": "http://c"}, Transport{}, Transport{HttpsProxy: "http://b"}},
+		{"http", map[string]string{"HTTP_PROXY": "http://c"}, Transport{}, Transport{HttpsProxy: "http://c"}},
+		{"socks", map[string]string{"ALL_PROXY": "socks5://d"}, Transport{}, Transport{}},
+		{"set", map[string]string{"HTTPS_PROXY": "http://a"}, Transport{HttpsProxy: "http://x"}, Transport{HttpsProxy: "http://x"}},
+		{
+			"no proxy",
+			map[string]string{"HTTPS_PROXY": "http://a", "NO_PROXY": ".aliyuncs.com"},
+			Transport{NoProxy: "internal"},
+			Transport{NoProxy: "internal,devops.aliyuncs.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			got := tt.t
+			got.proxyFromEnvironment("devops.aliyuncs.com")
+			if got != tt.want {
+				t.Errorf("Transport = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package codeup
+
+import (
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// validateSequence checks that ms has no gaps between versions
+// and that no migration in duplicates was found.
+func validateSequence(ms *source.Migrations, duplicates []*source.Migration) error {
+	problems := duplicateProblems(duplicates)
+
+	v, ok := ms.First()
+	for ok {
+		next, found := ms.Next(v)
+		if found && next != v+1 {
+			problems = append(problems, fmt.Sprintf("gap between versions %d and %d", v, next))
+		}
+		v, ok = next, found
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Validate checks the loaded migrations without reading any file:
+// every version must have both an up and a down migration
+// (only an up one if Option.UpOnly is set),
+// no version may have two migrations of the same direction,
+// and the up and down migrations of a version must have the same identifier.
+// All problems found are reported in one *ValidationError.
+func (s *CodeUp) Validate() error {
+	ms, err := s.loaded()
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	problems := duplicateProblems(s.duplicates)
+	s.mu.RUnlock()
+
+	v, ok := ms.First()
+	for ok {
+		up, hasUp := ms.Up(v)
+		down, hasDown := ms.Down(v)
+		if hasUp && hasDown && up.Identifier != down.Identifier {
+			problems = append(problems, identifierProblem(up, down))
+		}
+		v, ok = ms.Next(v)
+	}
+	problems = append(problems, pairProblems(ms, !s.option.UpOnly)...)
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validatePairs checks that each version in ms has both an up and a down migration.
+func validatePairs(ms *source.Migrations) error {
+	if problems := pairProblems(ms, true); len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// pairProblems lists the versions of ms without an up migration,
+// and without a down migration if down is set.
+func pairProblems(ms *source.Migrations, down bool) []string {
+	var problems []string
+	v, ok := ms.First()
+	for ok {
+		upM, hasUp := ms.Up(v)
+		downM, hasDown := ms.Down(v)
+		switch {
+		case !hasUp:
+			problems = append(problems, fmt.Sprintf("missing up migration for version %d: %s", v, downM.Raw))
+		case !hasDown && down:
+			problems = append(problems, fmt.Sprintf("missing down migration for version %d: %s", v, upM.Raw))
+		}
+		v, ok = ms.Next(v)
+	}
+	return problems
+}
+
+func duplicateProblems(duplicates []*source.Migration) []string {
+	var problems []string
+	for _, m := range duplicates {
+		problems = append(problems, fmt.Sprintf("duplicate %s migration for version %d: %s", m.Direction, m.Version, m.Raw))
+	}
+	return problems
+}
+
+// validateIdentifiers checks that the up and down migrations
+// of each version in ms have the same identifier.
+func validateIdentifiers(ms *source.Migrations) error {
+	var problems []string
+	v, ok := ms.First()
+	for ok {
+		up, hasUp := ms.Up(v)
+		down, hasDown := ms.Down(v)
+		if hasUp && hasDown && up.Identifier != down.Identifier {
+			problems = append(problems, identifierProblem(up, down))
+		}
+		v, ok = ms.Next(v)
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func identifierProblem(up, down *source.Migration) string {
+	return fmt.Sprintf("version %d has up identifier %q but down identifier %q: %s, %s", up.Version, up.Identifier, down.Identifier, up.Raw, down.Raw)
+}
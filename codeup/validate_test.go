@@ -0,0 +1,67 @@
+package codeup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		upOnly bool
+		want   []string
+	}{
+		{"valid", map[string]string{"db/1_a.up.sql": "", "db/1_a.down.sql": ""}, false, nil},
+		{
+			"missing down",
+			map[string]string{"db/1_a.up.sql": "", "db/2_b.down.sql": ""},
+			false,
+			[]string{
+				"missing down migration for version 1: 1_a.up.sql",
+				"missing up migration for version 2: 2_b.down.sql",
+			},
+		},
+		{"up only", map[string]string{"db/1_a.up.sql": ""}, true, nil},
+		{
+			"identifiers",
+			map[string]string{"db/1_a.up.sql": "", "db/1_b.down.sql": ""},
+			false,
+			[]string{`version 1 has up identifier "a" but down identifier "b": 1_a.up.sql, 1_b.down.sql`},
+		},
+		{
+			"duplicates",
+			map[string]string{"db/x/1_a.up.sql": "", "db/y/1_a.up.sql": "", "db/x/1_a.down.sql": ""},
+			false,
+			[]string{"duplicate up migration for version 1: y/1_a.up.sql"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option := testOption("db")
+			option.Config.Recursive = true
+			option.UpOnly = tt.upOnly
+			d, err := WithInstance(newFakeClient(tt.files), option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			err = d.(*CodeUp).Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(verr.Problems, tt.want) {
+				t.Errorf("problems = %q, want %q", verr.Problems, tt.want)
+			}
+		})
+	}
+}